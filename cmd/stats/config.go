@@ -0,0 +1,79 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var configFormat string
+
+func newConfigCmd(cfg config.Config) *cobra.Command {
+	var configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	var showCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective config and the source of each value",
+		Long: `Print the effective config and where each value comes from. Values are layered
+as defaults < config file (--config) < environment variables < flags, so the same
+flags as the stats command can be given to see their effect.`,
+		Args:    cobra.NoArgs,
+		RunE:    runE(runConfigShow),
+		Example: `./parser config show --config ./parser.yaml --postcode 10121`,
+	}
+	addStatsFlags(showCmd, cfg)
+	showCmd.Flags().StringVar(&configFormat, "format", "text", "Output format, text or json (optional)")
+
+	configCmd.AddCommand(showCmd)
+	return configCmd
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	if configFormat != "text" && configFormat != "json" {
+		return usageError(errors.Errorf("invalid format %q, must be text or json", configFormat))
+	}
+
+	cfg, sources, err := loadConfig(cmd)
+	if err != nil {
+		return inputError(errors.Wrap(err, "failed to read config"))
+	}
+
+	fields := cfg.Fields(sources)
+	if configFormat == "json" {
+		return printJSON(cmd.OutOrStdout(), fields)
+	}
+	printFields(cmd.OutOrStdout(), fields)
+	return nil
+}
+
+// printFields prints the config as an aligned key, value, source table
+func printFields(w io.Writer, fields []config.Field) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, field := range fields {
+		value := fmt.Sprint(field.Value)
+		switch v := field.Value.(type) {
+		case []string:
+			value = strings.Join(v, ",")
+		case map[string]config.Profile:
+			// profile definitions are too long for a table row, list their names
+			names := make([]string, 0, len(v))
+			for name := range v {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			value = strings.Join(names, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", field.Key, value, field.Source)
+	}
+	tw.Flush()
+}
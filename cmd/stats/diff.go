@@ -0,0 +1,156 @@
+package stats
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+	"github.com/rashad-j/jsonreader/pkg/stats"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var diffFormat string
+
+func newDiffCmd(cfg config.Config) *cobra.Command {
+	var diffCmd = &cobra.Command{
+		Use:   "diff OLD NEW",
+		Short: "Compare two stats runs, given as stats output or fixtures files",
+		Long: `Compare two stats runs. Each argument is either the JSON output of the stats command,
+or a fixtures file (or directory) whose stats are calculated first using the postcode,
+time window and words flags.`,
+		Args:    cobra.ExactArgs(2),
+		RunE:    runE(runDiff),
+		Example: `./parser diff ./yesterday.json ./today.json --format text`,
+	}
+
+	addQueryFlags(diffCmd, cfg)
+	diffCmd.Flags().StringVar(&diffFormat, "format", "json", "Output format, json or text (optional)")
+
+	return diffCmd
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	if diffFormat != "json" && diffFormat != "text" {
+		return usageError(errors.Errorf("invalid format %q, must be json or text", diffFormat))
+	}
+
+	cfg, _, err := loadConfig(cmd)
+	if err != nil {
+		return inputError(errors.Wrap(err, "failed to read config"))
+	}
+	if err := cfg.ValidateQuery(); err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	normalizer := sharedNormalizer(cfg)
+	oldData, err := loadResponseData(ctx, args[0], cfg, normalizer)
+	if err != nil {
+		return errors.Wrap(err, "failed to load old stats")
+	}
+	newData, err := loadResponseData(ctx, args[1], cfg, normalizer)
+	if err != nil {
+		return errors.Wrap(err, "failed to load new stats")
+	}
+
+	diff := stats.Diff(oldData, newData)
+	if diffFormat == "text" {
+		printDiff(cmd.OutOrStdout(), diff)
+		return nil
+	}
+	return printJSON(cmd.OutOrStdout(), diff)
+}
+
+// loadResponseData reads the output of the stats command, or calculates the stats
+// when path is a fixtures file or directory, normalizing recipes with normalizer
+func loadResponseData(ctx context.Context, path string, cfg config.Config, normalizer *parser.Normalizer) (stats.ResponseData, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return stats.ResponseData{}, inputError(errors.Wrap(err, "failed to stat file"))
+	}
+	if !info.IsDir() {
+		file, err := os.Open(path)
+		if err != nil {
+			return stats.ResponseData{}, inputError(errors.Wrap(err, "failed to open file"))
+		}
+		defer file.Close()
+
+		// stats output is a JSON object, fixtures are a JSON array
+		reader := bufio.NewReader(file)
+		if first, err := firstNonSpace(reader); err == nil && first == '{' {
+			var data stats.ResponseData
+			if err := json.NewDecoder(reader).Decode(&data); err != nil {
+				return stats.ResponseData{}, inputError(errors.Wrap(err, "failed to decode stats"))
+			}
+			return data, nil
+		}
+	}
+
+	log.Info().Str("file", path).Msg("Calculating stats...")
+	p := newParser(cfg.WithFile(path)).WithNormalizer(normalizer)
+	go p.Parse(ctx)
+	return stats.NewJsonStats(p, cfg).WithMetrics(collector).Generate(ctx)
+}
+
+// firstNonSpace peeks the first non-whitespace byte without consuming it
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			reader.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
+
+// printDiff prints the diff in a human-readable form
+func printDiff(w io.Writer, diff stats.DiffData) {
+	fmt.Fprintf(w, "Unique recipes: %d -> %d (%+d)\n", diff.UniqueRecipeCount.Old, diff.UniqueRecipeCount.New, diff.UniqueRecipeCount.Delta)
+
+	fmt.Fprintf(w, "Added recipes: %d\n", len(diff.AddedRecipes))
+	for _, rc := range diff.AddedRecipes {
+		fmt.Fprintf(w, "  + %s (%d)\n", rc.Recipe, rc.Count)
+	}
+	fmt.Fprintf(w, "Removed recipes: %d\n", len(diff.RemovedRecipes))
+	for _, rc := range diff.RemovedRecipes {
+		fmt.Fprintf(w, "  - %s (%d)\n", rc.Recipe, rc.Count)
+	}
+	fmt.Fprintf(w, "Changed counts: %d\n", len(diff.CountDeltas))
+	for _, rd := range diff.CountDeltas {
+		fmt.Fprintf(w, "  %s: %d -> %d (%+d)\n", rd.Recipe, rd.Old, rd.New, rd.Delta)
+	}
+
+	busiest := diff.BusiestPostcode
+	if busiest.Changed {
+		fmt.Fprintf(w, "Busiest postcode: %s (%d) -> %s (%d)\n", busiest.Old.Postcode, busiest.Old.DeliveryCount, busiest.New.Postcode, busiest.New.DeliveryCount)
+	} else {
+		fmt.Fprintf(w, "Busiest postcode: %s unchanged, %d -> %d deliveries\n", busiest.New.Postcode, busiest.Old.DeliveryCount, busiest.New.DeliveryCount)
+	}
+
+	window := diff.CountPerPostcodeAndTime
+	fmt.Fprintf(w, "Deliveries to %s between %s and %s: %d -> %d (%+d)\n", window.New.Postcode, window.New.From, window.New.To, window.Old.DeliveryCount, window.New.DeliveryCount, window.Delta)
+	if window.Old.Postcode != window.New.Postcode || window.Old.From != window.New.From || window.Old.To != window.New.To {
+		fmt.Fprintf(w, "  note: the old run counted %s between %s and %s\n", window.Old.Postcode, window.Old.From, window.Old.To)
+	}
+
+	for _, name := range diff.AddedMatches {
+		fmt.Fprintf(w, "Matching recipe added: %s\n", name)
+	}
+	for _, name := range diff.RemovedMatches {
+		fmt.Fprintf(w, "Matching recipe removed: %s\n", name)
+	}
+}
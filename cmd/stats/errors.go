@@ -0,0 +1,107 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of the parser binary, see ExitCode
+const (
+	ExitOK         = 0
+	ExitInternal   = 1
+	ExitUsage      = 2
+	ExitInput      = 3
+	ExitValidation = 4
+	// same as timeout(1) and a shell killed by SIGINT
+	ExitTimeout     = 124
+	ExitInterrupted = 130
+)
+
+// exitError attaches an exit code to an error returned by a command
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// usageError marks wrong arguments or flag values
+func usageError(err error) error {
+	return &exitError{code: ExitUsage, err: err}
+}
+
+// inputError marks input that cannot be read, e.g. a missing or corrupt file
+func inputError(err error) error {
+	return &exitError{code: ExitInput, err: err}
+}
+
+// ExitCode returns the process exit code for an error returned by ExecuteStatsCMD:
+// 0 on success, 2 for usage errors, 3 for input errors, 4 for validation failures,
+// 124 when --timeout expired, 130 on SIGINT/SIGTERM and 1 for everything else
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	var validationErrs config.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return ExitValidation
+	}
+	var parserInputErr *parser.InputError
+	if errors.As(err, &parserInputErr) {
+		return ExitInput
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ExitTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return ExitInterrupted
+	}
+
+	return ExitInternal
+}
+
+// runE tags the error of a command run with its exit code. Errors without a code
+// come from cobra itself, e.g. an unknown command or flag, see execute.
+func runE(run func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		err := run(cmd, args)
+		switch code := ExitCode(err); code {
+		case ExitOK:
+			return nil
+		case ExitTimeout:
+			return &exitError{code: code, err: errors.Wrapf(err, "timed out after %s", timeout)}
+		case ExitInterrupted:
+			return &exitError{code: code, err: errors.Wrap(err, "interrupted")}
+		default:
+			return &exitError{code: code, err: err}
+		}
+	}
+}
+
+// PrintError writes err to w, with a pointer to the help for usage errors
+func PrintError(w io.Writer, err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintln(w, "Error:", err)
+	if ExitCode(err) == ExitUsage {
+		fmt.Fprintln(w, "Run 'parser --help' for usage.")
+	}
+}
@@ -0,0 +1,254 @@
+package stats
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configFile string
+	timeout    time.Duration
+
+	fileName string
+	engine   string
+	fields   fieldMappingValue
+	extended bool
+	fromTime string
+	toTime   string
+	postcode string
+	words    string
+	state    string
+	profile  string
+	helpFlag bool
+
+	crosstab             bool
+	crosstabPostcodes    string
+	crosstabTopPostcodes int
+	crosstabTopRecipes   int
+
+	normalize              bool
+	normalizePostcodeWidth int
+	aliases                string
+	showAliases            bool
+
+	dedupe              bool
+	dedupeCapacity      int
+	dedupeFalsePositive float64
+
+	approximate     bool
+	approxPrecision int
+	approxEpsilon   float64
+	approxDelta     float64
+	approxTop       int
+)
+
+// addStatsFlags adds every flag that overrides a config value of the stats command
+func addStatsFlags(cmd *cobra.Command, cfg config.Config) {
+	cmd.Flags().StringVarP(&fileName, "file", "f", cfg.File, "File, or directory of .json files, to use (optional)")
+	cmd.Flags().StringVar(&engine, "engine", cfg.Engine, "Parser engine, json or fast (memory-mapped scanner) (optional)")
+	fields = fieldMappingValue(cfg.FieldMapping)
+	cmd.Flags().Var(&fields, "fields", "Comma-separated JSON pointers of the record fields, e.g. postcode=/zip,recipe=/meal_name (optional)")
+	cmd.Flags().BoolVar(&extended, "extended", cfg.Extended, "Read the optional customer_id, box_size, order_date and price fields and add box unit and revenue stats (optional)")
+	cmd.Flags().BoolVar(&normalize, "normalize", cfg.Normalize, "Clean up whitespace, Unicode and case of recipe names and postcodes before counting (optional)")
+	cmd.Flags().IntVar(&normalizePostcodeWidth, "normalize-postcode-width", cfg.NormalizePostcodeWidth, "Pad numeric postcodes with zeros to this width when normalizing, 0 disables it (optional)")
+	cmd.Flags().StringVar(&aliases, "aliases", cfg.Aliases, "File mapping canonical recipe names to their aliases, the aliases are counted under the canonical name (optional)")
+	cmd.Flags().BoolVar(&showAliases, "show-aliases", cfg.ShowAliases, "List the aliases counted under each recipe (optional)")
+	cmd.Flags().BoolVar(&dedupe, "dedupe", cfg.Dedupe, "Drop records repeating the postcode, recipe, delivery and customer_id of an earlier record (optional)")
+	cmd.Flags().IntVar(&dedupeCapacity, "dedupe-capacity", cfg.DedupeCapacity, "Number of records the dedupe filter holds at its false-positive rate, about 2.4 bytes each at the default rate, also the size of its file next to --state (optional)")
+	cmd.Flags().Float64Var(&dedupeFalsePositive, "dedupe-false-positive", cfg.DedupeFalsePositive, "Probability that the dedupe filter drops a record that is not a duplicate (optional)")
+	addQueryFlags(cmd, cfg)
+	cmd.Flags().StringVar(&profile, "profile", strings.Join(cfg.Profile, ","), "Comma-separated profiles of the config file to run, several are run in one pass (optional)")
+	cmd.Flags().StringVar(&state, "state", cfg.State, "File persisting the aggregation state, later runs only process new input files (optional)")
+	cmd.Flags().BoolVar(&crosstab, "crosstab", cfg.Crosstab, "Add recipe counts per postcode to the output (optional)")
+	cmd.Flags().StringVar(&crosstabPostcodes, "crosstab-postcodes", strings.Join(cfg.CrosstabPostcodes, ","), "Comma-separated postcodes for the crosstab, defaults to the busiest postcodes (optional)")
+	cmd.Flags().IntVar(&crosstabTopPostcodes, "crosstab-top-postcodes", cfg.CrosstabTopPostcodes, "Number of busiest postcodes in the crosstab when no postcodes are given (optional)")
+	cmd.Flags().IntVar(&crosstabTopRecipes, "crosstab-top-recipes", cfg.CrosstabTopRecipes, "Number of recipes per postcode in the crosstab, 0 lists all (optional)")
+	cmd.Flags().BoolVar(&approximate, "approximate", cfg.Approximate, "Estimate stats in fixed memory using sketches (optional)")
+	cmd.Flags().IntVar(&approxPrecision, "approx-precision", cfg.ApproxPrecision, "HyperLogLog precision, uses 2^precision bytes per distinct count (optional)")
+	cmd.Flags().Float64Var(&approxEpsilon, "approx-epsilon", cfg.ApproxEpsilon, "Count-Min relative error, estimates overcount by at most epsilon × deliveries (optional)")
+	cmd.Flags().Float64Var(&approxDelta, "approx-delta", cfg.ApproxDelta, "Count-Min failure probability of the error bound (optional)")
+	cmd.Flags().IntVar(&approxTop, "approx-top", cfg.ApproxTop, "Number of heavy-hitter recipes to report (optional)")
+}
+
+// addQueryFlags adds the postcode, time window and words flags shared by the commands
+func addQueryFlags(cmd *cobra.Command, cfg config.Config) {
+	cmd.Flags().StringVarP(&fromTime, "fromTime", "s", cfg.FromTime, "From time (optional)")
+	cmd.Flags().StringVarP(&toTime, "toTime", "e", cfg.ToTime, "To time (optional)")
+	cmd.Flags().StringVarP(&postcode, "postcode", "p", cfg.Postcode, "Postcode (required)")
+	cmd.Flags().StringVarP(&words, "words", "w", strings.Join(cfg.Words, ","), "List of comma-separated words (optional)")
+}
+
+// loadConfig layers the defaults, the config file and the environment (see
+// config.Load) and applies the flags of cmd that were set on the command line.
+// Tracking flags by Changed means passing a flag with its default value still
+// overrides the file and the environment. A single selected profile is applied
+// before the flags, several are applied per run by profileConfigs.
+func loadConfig(cmd *cobra.Command) (config.Config, config.Sources, error) {
+	cfg, sources, err := config.Load(configFile)
+	if err != nil {
+		return config.Config{}, nil, err
+	}
+
+	if cmd.Flags().Changed("profile") {
+		cfg = cfg.WithProfile(splitList(profile))
+		sources["profile"] = config.SourceFlag
+	}
+	// an unknown profile is left to Validate, so it is reported with all other problems
+	if len(cfg.Profile) == 1 {
+		if profileCfg, keys, err := cfg.ApplyProfile(cfg.Profile[0]); err == nil {
+			cfg = profileCfg
+			for _, key := range keys {
+				sources[key] = config.SourceProfile
+			}
+		}
+	}
+
+	cfg = applyFlags(cmd, cfg, sources)
+	// every command loads the config first, so the logs follow it from here on
+	setupLogging(cfg, cmd.ErrOrStderr())
+
+	return cfg, sources, nil
+}
+
+// profileConfigs returns the config of every selected profile, the flags set on the
+// command line still override the profile values
+func profileConfigs(cmd *cobra.Command, cfg config.Config) (map[string]config.Config, error) {
+	configs := make(map[string]config.Config, len(cfg.Profile))
+	for _, name := range cfg.Profile {
+		profileCfg, _, err := cfg.ApplyProfile(name)
+		if err != nil {
+			return nil, err
+		}
+		configs[name] = applyFlags(cmd, profileCfg, config.Sources{})
+	}
+	return configs, nil
+}
+
+// applyFlags overrides the config with the flags set on the command line and
+// records them as the source of those values
+func applyFlags(cmd *cobra.Command, cfg config.Config, sources config.Sources) config.Config {
+	flags := cmd.Flags()
+	changed := func(flag, key string) bool {
+		if !flags.Changed(flag) {
+			return false
+		}
+		sources[key] = config.SourceFlag
+		return true
+	}
+
+	if changed("file", "file") {
+		cfg = cfg.WithFile(fileName)
+	}
+	if changed("engine", "engine") {
+		cfg = cfg.WithEngine(engine)
+	}
+	if changed("fields", "fields") {
+		cfg = cfg.WithFieldMapping(config.FieldMapping(fields))
+	}
+	if changed("extended", "extended") {
+		cfg = cfg.WithExtended(extended)
+	}
+	if changed("normalize", "normalize") {
+		cfg = cfg.WithNormalize(normalize)
+	}
+	if changed("normalize-postcode-width", "normalize_postcode_width") {
+		cfg = cfg.WithNormalizePostcodeWidth(normalizePostcodeWidth)
+	}
+	if changed("aliases", "aliases") {
+		cfg = cfg.WithAliases(aliases)
+	}
+	if changed("show-aliases", "show_aliases") {
+		cfg = cfg.WithShowAliases(showAliases)
+	}
+	if changed("dedupe", "dedupe") {
+		cfg = cfg.WithDedupe(dedupe)
+	}
+	if changed("dedupe-capacity", "dedupe_capacity") {
+		cfg = cfg.WithDedupeCapacity(dedupeCapacity)
+	}
+	if changed("dedupe-false-positive", "dedupe_false_positive") {
+		cfg = cfg.WithDedupeFalsePositive(dedupeFalsePositive)
+	}
+	if changed("fromTime", "from") {
+		cfg = cfg.WithFromTime(fromTime)
+	}
+	if changed("toTime", "to") {
+		cfg = cfg.WithToTime(toTime)
+	}
+	if changed("postcode", "postcode") {
+		cfg = cfg.WithPostcode(postcode)
+	}
+	if changed("words", "words") {
+		cfg = cfg.WithWords(splitList(words))
+	}
+	if changed("state", "state") {
+		cfg = cfg.WithState(state)
+	}
+	if changed("crosstab", "crosstab") {
+		cfg = cfg.WithCrosstab(crosstab)
+	}
+	if changed("crosstab-postcodes", "crosstab_postcodes") {
+		cfg = cfg.WithCrosstabPostcodes(splitList(crosstabPostcodes))
+	}
+	if changed("crosstab-top-postcodes", "crosstab_top_postcodes") {
+		cfg = cfg.WithCrosstabTopPostcodes(crosstabTopPostcodes)
+	}
+	if changed("crosstab-top-recipes", "crosstab_top_recipes") {
+		cfg = cfg.WithCrosstabTopRecipes(crosstabTopRecipes)
+	}
+	if changed("approximate", "approximate") {
+		cfg = cfg.WithApproximate(approximate)
+	}
+	if changed("approx-precision", "approx_precision") {
+		cfg = cfg.WithApproxPrecision(approxPrecision)
+	}
+	if changed("approx-epsilon", "approx_epsilon") {
+		cfg = cfg.WithApproxEpsilon(approxEpsilon)
+	}
+	if changed("approx-delta", "approx_delta") {
+		cfg = cfg.WithApproxDelta(approxDelta)
+	}
+	if changed("approx-top", "approx_top") {
+		cfg = cfg.WithApproxTop(approxTop)
+	}
+	if changed("log-level", "log_level") {
+		cfg = cfg.WithLogLevel(logLevel)
+	}
+	if changed("log-format", "log_format") {
+		cfg = cfg.WithLogFormat(logFormat)
+	}
+	if changed("log-rejections", "log_rejections") {
+		cfg = cfg.WithLogRejections(logRejections)
+	}
+
+	return cfg
+}
+
+// fieldMappingValue reads the --fields flag, so a malformed mapping is a usage error
+type fieldMappingValue config.FieldMapping
+
+func (v *fieldMappingValue) String() string {
+	return config.FieldMapping(*v).String()
+}
+
+func (v *fieldMappingValue) Set(value string) error {
+	return (*config.FieldMapping)(v).UnmarshalText([]byte(value))
+}
+
+func (v *fieldMappingValue) Type() string {
+	return "mapping"
+}
+
+// splitList splits a comma-separated flag value, dropping whitespace and empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(strings.TrimSpace(value), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
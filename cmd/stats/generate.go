@@ -0,0 +1,118 @@
+package stats
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/fixtures"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	genRecords      int
+	genSeed         int64
+	genRecipes      string
+	genPostcodes    int
+	genDistribution string
+	genZipfS        float64
+	genWeekdays     map[string]string
+	genMalformed    map[string]string
+	genFormat       string
+	genOutput       string
+)
+
+func newGenerateCmd() *cobra.Command {
+	var generateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "Generate deterministic fixtures of any size",
+		Long: `Generate recipe deliveries for tests and benchmarks. The same seed and options
+always give the same records. Postcodes are drawn uniformly or following a Zipf
+distribution, weekdays by weight, and --malformed injects records rejected by the
+parser, as a share of all records per rule: ` + strings.Join(fixtures.Rules, ", ") + `.`,
+		Args:    cobra.NoArgs,
+		RunE:    runE(runGenerate),
+		Example: `./parser generate --records 1000000 --distribution zipf --weekdays Monday=3,Friday=1 --malformed invalid_delivery=0.01,empty_postcode=0.001 -o big.json`,
+	}
+
+	generateCmd.Flags().IntVarP(&genRecords, "records", "n", 1000, "Number of records (optional)")
+	generateCmd.Flags().Int64Var(&genSeed, "seed", 1, "Random seed (optional)")
+	generateCmd.Flags().StringVar(&genRecipes, "recipes", "", "Comma-separated recipe vocabulary, defaults to the recipes of the test fixtures (optional)")
+	generateCmd.Flags().IntVar(&genPostcodes, "postcodes", 1000, "Number of distinct postcodes, from 10000 (optional)")
+	generateCmd.Flags().StringVar(&genDistribution, "distribution", fixtures.Uniform, "Postcode distribution, uniform or zipf (optional)")
+	generateCmd.Flags().Float64Var(&genZipfS, "zipf-s", 1.1, "Zipf exponent, greater than 1, higher values favour the busiest postcodes (optional)")
+	generateCmd.Flags().StringToStringVar(&genWeekdays, "weekdays", nil, "Weekday weights, e.g. Monday=3,Friday=1, defaults to every weekday equally (optional)")
+	generateCmd.Flags().StringToStringVar(&genMalformed, "malformed", nil, "Share of malformed records per rule, e.g. invalid_delivery=0.01 (optional)")
+	generateCmd.Flags().StringVar(&genFormat, "format", fixtures.FormatJSON, "Output format, json, ndjson or csv (optional)")
+	generateCmd.Flags().StringVarP(&genOutput, "output", "o", "", "Output file, defaults to stdout (optional)")
+
+	return generateCmd
+}
+
+func runGenerate(cmd *cobra.Command, args []string) error {
+	if genFormat != fixtures.FormatJSON && genFormat != fixtures.FormatNDJSON && genFormat != fixtures.FormatCSV {
+		return usageError(errors.Errorf("invalid format %q, must be json, ndjson or csv", genFormat))
+	}
+	weekdays, err := parseWeights("weekdays", genWeekdays)
+	if err != nil {
+		return usageError(err)
+	}
+	malformed, err := parseWeights("malformed", genMalformed)
+	if err != nil {
+		return usageError(err)
+	}
+
+	generator := fixtures.NewGenerator(genRecords, genSeed).
+		WithPostcodes(genPostcodes, genDistribution, genZipfS).
+		WithWeekdays(weekdays).
+		WithMalformed(malformed)
+	if genRecipes != "" {
+		generator = generator.WithRecipes(splitList(genRecipes))
+	}
+	if err := generator.Validate(); err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	var file *os.File
+	if genOutput != "" {
+		if file, err = os.Create(genOutput); err != nil {
+			return inputError(errors.Wrap(err, "failed to create output file"))
+		}
+		w = file
+	}
+
+	summary, err := generator.Write(w, genFormat)
+	if file != nil {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = errors.Wrap(closeErr, "failed to write output file")
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	event := log.Info().Int("records", summary.Records)
+	for _, rule := range fixtures.Rules {
+		if count := summary.Malformed[rule]; count > 0 {
+			event = event.Int(rule, count)
+		}
+	}
+	event.Msg("Generated fixtures")
+	return nil
+}
+
+// parseWeights parses the values of a key=value flag as numbers
+func parseWeights(flag string, values map[string]string) (map[string]float64, error) {
+	weights := make(map[string]float64, len(values))
+	for key, value := range values {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid --%s value %s=%s, must be a number", flag, key, value)
+		}
+		weights[key] = weight
+	}
+	return weights, nil
+}
@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Regenerate the golden files of testdata/golden")
+
+// cliResult is the outcome of one in-process command line run
+type cliResult struct {
+	stdout string
+	stderr string
+	code   int
+}
+
+// runCLI runs the command line with args and the environment variables of env,
+// like main does, and captures its output and exit code
+func runCLI(t *testing.T, env map[string]string, args ...string) cliResult {
+	t.Helper()
+	t.Setenv("CONFIG", "")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	var stdout, stderr bytes.Buffer
+	rootCmd := newRootCmd()
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	err := execute(context.Background(), rootCmd)
+	PrintError(&stderr, err)
+
+	return cliResult{stdout: stdout.String(), stderr: stderr.String(), code: ExitCode(err)}
+}
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		// golden is the file of testdata/golden with the expected stdout, several
+		// runs expected to agree share one
+		golden string
+		code   int
+		// stderr is expected in the error output
+		stderr string
+	}{
+		{name: "defaults", args: []string{"stats", "--file", testFile}, golden: "defaults.json"},
+		{name: "fast engine", args: []string{"stats", "--file", testFile, "--engine", "fast"}, golden: "defaults.json"},
+		{name: "fast engine from env", args: []string{"stats", "--file", testFile}, env: map[string]string{"ENGINE": "fast"}, golden: "defaults.json"},
+		{name: "query", args: []string{"stats", "--file", testFile, "--postcode", "10224", "--fromTime", "1AM", "--toTime", "9PM", "--words", "Chicken,Pork"}, golden: "query.json"},
+		{name: "query from env", args: []string{"stats", "--file", testFile}, env: map[string]string{"POSTCODE": "10224", "FROM": "1AM", "TO": "9PM", "WORDS": "Chicken,Pork"}, golden: "query.json"},
+		{name: "config file", args: []string{"stats", "--file", testFile, "--config", "testdata/config.yaml"}, golden: "config_file.json"},
+		{name: "flags over config file", args: []string{"stats", "--file", testFile, "--config", "testdata/config.yaml", "--postcode", "10120", "--words", "Potato,Mushroom,Veggie"}, golden: "defaults.json"},
+		{name: "rules", args: []string{"stats", "--file", testFile, "--config", "testdata/rules.yaml", "--words", "Chicken,Pork"}, golden: "rules.json"},
+		{name: "profile", args: []string{"stats", "--file", testFile, "--config", "testdata/config.yaml", "--profile", "morning"}, golden: "profile.json"},
+		{name: "profiles", args: []string{"stats", "--file", testFile, "--config", "testdata/config.yaml", "--profile", "morning,evening"}, golden: "profiles.json"},
+		{name: "crosstab", args: []string{"stats", "--file", testFile, "--crosstab", "--crosstab-top-postcodes", "3", "--crosstab-top-recipes", "2"}, golden: "crosstab.json"},
+		{name: "approximate", args: []string{"stats", "--file", testFile, "--approximate"}, golden: "approximate.json"},
+		{name: "field mapping", args: []string{"stats", "--file", "testdata/export.json", "--fields", "recipe=/meal_name,postcode=/address/zip,delivery=/slots/0/window", "--postcode", "10224", "--words", "Chicken"}, golden: "fields.json"},
+		{name: "field mapping from env", args: []string{"stats", "--file", "testdata/export.json", "--postcode", "10224", "--words", "Chicken"}, env: map[string]string{"FIELDS": "recipe=/meal_name,postcode=/address/zip,delivery=/slots/0/window"}, golden: "fields.json"},
+		{name: "extended", args: []string{"stats", "--file", "../../pkg/stats/testdata/extended.json", "--extended", "--engine", "fast", "--words", "Chicken"}, golden: "extended.json"},
+		{name: "normalize", args: []string{"stats", "--file", "testdata/variants.json", "--normalize", "--normalize-postcode-width", "5", "--postcode", "10224", "--words", "Tilapia"}, golden: "normalize.json"},
+		{name: "normalize from env", args: []string{"stats", "--file", "testdata/variants.json", "--postcode", "10224", "--words", "Tilapia"}, env: map[string]string{"NORMALIZE": "true", "NORMALIZE_POSTCODE_WIDTH": "5"}, golden: "normalize.json"},
+		{name: "aliases", args: []string{"stats", "--file", "testdata/renamed.json", "--aliases", "testdata/aliases.yaml", "--show-aliases", "--words", "Fajitas"}, golden: "aliases.json"},
+		{name: "aliases normalized", args: []string{"stats", "--file", "testdata/renamed.json", "--aliases", "testdata/aliases.yaml", "--normalize", "--words", "Fajitas"}, golden: "aliases_normalized.json"},
+		{name: "dedupe", args: []string{"stats", "--file", "testdata/duplicates.json", "--dedupe", "--dedupe-capacity", "1000", "--words", "Chicken"}, golden: "dedupe.json"},
+		{name: "dedupe fast engine", args: []string{"stats", "--file", "testdata/duplicates.json", "--dedupe", "--dedupe-capacity", "1000", "--engine", "fast", "--words", "Chicken"}, golden: "dedupe.json"},
+		{name: "diff", args: []string{"diff", testFile, testFile}, golden: "diff.json"},
+		{name: "generate", args: []string{"generate", "--records", "20", "--seed", "3", "--malformed", "invalid_delivery=0.2"}, golden: "generate.json"},
+		{name: "generate csv", args: []string{"generate", "--records", "5", "--format", "csv", "--distribution", "zipf"}, golden: "generate.csv"},
+		{name: "invalid postcode", args: []string{"stats", "--file", testFile, "--postcode", "!"}, golden: "empty", code: ExitValidation, stderr: "Error: invalid config:"},
+		{name: "malformed field mapping", args: []string{"stats", "--file", testFile, "--fields", "zip=/zip"}, golden: "empty", code: ExitUsage, stderr: `unknown field "zip"`},
+		{name: "overlapping field paths", args: []string{"stats", "--file", testFile, "--fields", "postcode=/address,delivery=/address/slot"}, golden: "empty", code: ExitValidation, stderr: "overlapping paths"},
+		{name: "extended in approximate mode", args: []string{"stats", "--file", testFile, "--extended", "--approximate"}, golden: "empty", code: ExitValidation, stderr: "extended (true): is not supported in approximate mode"},
+		{name: "postcode width without normalize", args: []string{"stats", "--file", testFile, "--normalize-postcode-width", "5"}, golden: "empty", code: ExitValidation, stderr: "normalize_postcode_width (5): only applies with normalize"},
+		{name: "invalid alias file", args: []string{"stats", "--file", testFile, "--aliases", "testdata/config.yaml"}, golden: "empty", code: ExitValidation, stderr: "aliases (testdata/config.yaml):"},
+		{name: "invalid dedupe settings", args: []string{"stats", "--file", testFile, "--dedupe", "--dedupe-false-positive", "0"}, golden: "empty", code: ExitValidation, stderr: "dedupe_false_positive (0): must be between 0 and 1"},
+		{name: "unknown flag", args: []string{"stats", "--colour"}, golden: "empty", code: ExitUsage, stderr: "Run 'parser --help' for usage."},
+		{name: "missing input file", args: []string{"stats", "--file", "testdata/missing.json"}, golden: "empty", code: ExitValidation, stderr: "testdata/missing.json"},
+	}
+
+	updated := make(map[string]bool)
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			got := runCLI(t, tt.env, tt.args...)
+			if got.code != tt.code {
+				t.Errorf("Expected exit code %v, but got %v: %s", tt.code, got.code, got.stderr)
+			}
+			if !strings.Contains(got.stderr, tt.stderr) {
+				t.Errorf("Expected %q in stderr, but got %q", tt.stderr, got.stderr)
+			}
+
+			path := filepath.Join("testdata", "golden", tt.golden)
+			if *update && !updated[path] {
+				updated[path] = true
+				if err := os.WriteFile(path, []byte(got.stdout), 0o644); err != nil {
+					t.Fatalf("Error writing golden file: %v", err)
+				}
+			}
+			expected, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Error reading golden file, run go test ./cmd/stats -run TestGolden -update: %v", err)
+			}
+			if got.stdout != string(expected) {
+				t.Errorf("Expected stdout %s, but got %s", expected, got.stdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package stats
+
+import (
+	"io"
+	"os"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	logLevel      string
+	logFormat     string
+	logRejections int
+)
+
+func init() {
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMs
+	// logs until the config is loaded, see loadConfig
+	setupLogging(config.Default(), os.Stderr)
+}
+
+// setupLogging configures the global logger from the log level and format of cfg.
+// Invalid values are left to Validate and keep the current logger.
+func setupLogging(cfg config.Config, w io.Writer) {
+	level, err := zerolog.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return
+	}
+	switch cfg.LogFormat {
+	case "json":
+		log.Logger = zerolog.New(w).With().Timestamp().Logger().Level(level)
+	case "console":
+		log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: w, TimeFormat: "2006-01-02 15:04:05"}).With().Timestamp().Logger().Level(level)
+	}
+}
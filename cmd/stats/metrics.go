@@ -0,0 +1,68 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	metricsDump  bool
+	metricsAddr  string
+	pprofEnabled bool
+
+	// collector instruments the parsers and stats of the command run
+	collector metrics.Metrics = metrics.Nop{}
+	registry  *metrics.Registry
+	server    *http.Server
+)
+
+// setupMetrics collects metrics for --metrics, and serves them on --metrics-addr
+// while the command runs, with the pprof endpoints for --pprof
+func setupMetrics(cmd *cobra.Command, args []string) error {
+	collector, registry, server = metrics.Nop{}, nil, nil
+	if pprofEnabled && metricsAddr == "" {
+		return errors.New("--pprof requires --metrics-addr")
+	}
+	if !metricsDump && metricsAddr == "" {
+		return nil
+	}
+	registry = metrics.NewRegistry()
+	collector = registry
+	if metricsAddr == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", metricsAddr)
+	if err != nil {
+		return &exitError{code: ExitInternal, err: errors.Wrap(err, "failed to serve metrics")}
+	}
+	server = &http.Server{Handler: registry.Handler(pprofEnabled)}
+	go server.Serve(listener)
+	log.Info().Str("addr", listener.Addr().String()).Bool("pprof", pprofEnabled).Msg("Serving metrics")
+
+	return nil
+}
+
+// finishMetrics stops the metrics server, and prints the metrics as JSON to w for --metrics
+func finishMetrics(w io.Writer) {
+	if server != nil {
+		server.Close()
+	}
+	if !metricsDump || registry == nil {
+		return
+	}
+	jsonData, err := json.MarshalIndent(registry.Snapshot(), "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal metrics")
+		return
+	}
+	fmt.Fprintln(w, string(jsonData))
+}
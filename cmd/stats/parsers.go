@@ -0,0 +1,25 @@
+package stats
+
+import (
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+// newParser creates the parser of cfg, reporting progress and metrics when enabled
+func newParser(cfg config.Config) *parser.JsonParser {
+	p := parser.NewJsonParser(cfg).WithMetrics(collector)
+	if progressFunc != nil {
+		p.WithProgress(progressFunc, progressInterval)
+	}
+	return p
+}
+
+// sharedNormalizer returns the normalizer shared by the parsers of several input
+// files, so they group recipe names under the same display names. It is nil
+// without normalization.
+func sharedNormalizer(cfg config.Config, names ...string) *parser.Normalizer {
+	if !cfg.Normalize {
+		return nil
+	}
+	return parser.NewNormalizer(cfg).WithNames(names)
+}
@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+	"github.com/spf13/cobra"
+)
+
+// progressInterval is the time between two progress lines
+const progressInterval = 500 * time.Millisecond
+
+var (
+	progressMode string
+
+	// progressFunc is attached to every parser of the command run, nil disables progress
+	progressFunc parser.ProgressFunc
+)
+
+// setupProgress enables the progress reporter for --progress always, or for auto
+// when stderr is a terminal, so redirected logs are not cluttered with it
+func setupProgress(cmd *cobra.Command, args []string) error {
+	progressFunc = nil
+	switch progressMode {
+	case "always":
+	case "never":
+		return nil
+	case "auto":
+		if !isTerminal(cmd.ErrOrStderr()) {
+			return nil
+		}
+	default:
+		return errors.Errorf("invalid progress %q, must be auto, always or never", progressMode)
+	}
+	progressFunc = progressPrinter(cmd.ErrOrStderr())
+	return nil
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+// progressPrinter rewrites a single progress line, which ends once parsing is done
+func progressPrinter(w io.Writer) parser.ProgressFunc {
+	return func(p parser.Progress) {
+		fmt.Fprintf(w, "\r\033[K%s", formatProgress(p))
+		if p.Done {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// formatProgress formats e.g. "12.3 MB / 480.0 MB (2.6%), 35000 records/s, ETA 2m10s, 12 rejected"
+func formatProgress(p parser.Progress) string {
+	percent := 100.0
+	if p.TotalBytes > 0 {
+		percent = 100 * float64(p.BytesRead) / float64(p.TotalBytes)
+	}
+	line := fmt.Sprintf("%s / %s (%.1f%%), %.0f records/s", formatBytes(p.BytesRead), formatBytes(p.TotalBytes), percent, p.Rate())
+	if p.Done {
+		line += fmt.Sprintf(", done in %s", p.Elapsed.Round(time.Second))
+	} else {
+		line += fmt.Sprintf(", ETA %s", p.ETA().Round(time.Second))
+	}
+	return line + fmt.Sprintf(", %d rejected", p.Rejected)
+}
+
+// formatBytes formats n in B, kB, MB or GB
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, "k"
+	for _, p := range []string{"M", "G"} {
+		if value < unit {
+			break
+		}
+		value, prefix = value/unit, p
+	}
+	return fmt.Sprintf("%.1f %sB", value, prefix)
+}
@@ -0,0 +1,41 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+func TestFormatProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress parser.Progress
+		expected string
+	}{
+		{
+			name:     "running",
+			progress: parser.Progress{BytesRead: 12_300_000, TotalBytes: 49_200_000, Records: 70_000, Rejected: 12, Elapsed: 2 * time.Second},
+			expected: "12.3 MB / 49.2 MB (25.0%), 35000 records/s, ETA 6s, 12 rejected",
+		},
+		{
+			name:     "done",
+			progress: parser.Progress{BytesRead: 900, TotalBytes: 900, Records: 3, Elapsed: 1500 * time.Millisecond, Done: true},
+			expected: "900 B / 900 B (100.0%), 2 records/s, done in 2s, 0 rejected",
+		},
+		{
+			name:     "gigabytes",
+			progress: parser.Progress{BytesRead: 1_500_000_000, TotalBytes: 3_000_000_000, Records: 10, Elapsed: 10 * time.Second},
+			expected: "1.5 GB / 3.0 GB (50.0%), 1 records/s, ETA 10s, 0 rejected",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatProgress(tt.progress); got != tt.expected {
+				t.Errorf("Expected %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
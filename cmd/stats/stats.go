@@ -1,129 +1,274 @@
 package stats
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"strings"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
 
+	"github.com/pkg/errors"
 	"github.com/rashad-j/jsonreader/pkg/config"
 	"github.com/rashad-j/jsonreader/pkg/parser"
 	"github.com/rashad-j/jsonreader/pkg/stats"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
-var (
-	fileName string
-	fromTime string
-	toTime   string
-	postcode string
-	words    string
-	helpFlag bool
-)
+// ExecuteStatsCMD runs the command line, use ExitCode to map the returned error
+// to the process exit code
+func ExecuteStatsCMD() error {
+	// SIGINT/SIGTERM cancel the command, the parser stops and the command returns
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func init() {
-	zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMs
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "2006-01-02 15:04:05"})
+	return execute(ctx, newRootCmd())
 }
 
-func ExecuteStatsCMD() error {
+func newRootCmd() *cobra.Command {
+	var rootCmd = &cobra.Command{
+		Use:   "parser",
+		Short: "Recipes statistics calculator",
+		// errors are printed by main, usage is only printed on request
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		PersistentPreRunE: setup,
+	}
+
+	// flag defaults are the built-in ones, the config file and environment are
+	// layered in once the flags are parsed, see loadConfig
+	cfg := config.Default()
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", os.Getenv("CONFIG"), "YAML, JSON or TOML config file (optional)")
+	rootCmd.PersistentFlags().StringVar(&progressMode, "progress", "auto", "Progress on stderr: auto (only on a terminal), always or never (optional)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", cfg.LogLevel, "Log level: trace, debug, info, warn, error or disabled (optional)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", cfg.LogFormat, "Log format on stderr, console or json (optional)")
+	rootCmd.PersistentFlags().IntVar(&logRejections, "log-rejections", cfg.LogRejections, "Rejected records logged per second, the rest are counted in a summary (optional)")
+	rootCmd.PersistentFlags().BoolVar(&metricsDump, "metrics", false, "Print parse and query metrics as JSON to stderr at the end (optional)")
+	rootCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus /metrics on this address while running, e.g. :9090 (optional)")
+	rootCmd.PersistentFlags().BoolVar(&pprofEnabled, "pprof", false, "Also serve /debug/pprof on --metrics-addr (optional)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Stop with an error after this duration, e.g. 30s, 0 means no limit (optional)")
+
+	rootCmd.AddCommand(newStatsCmd(cfg), newDiffCmd(cfg), newTrendCmd(cfg), newConfigCmd(cfg), newGenerateCmd())
+
+	return rootCmd
+}
+
+// execute runs the root command. Errors of a command run carry their exit code,
+// see runE, so any other error is a wrong command, flag or argument.
+func execute(ctx context.Context, rootCmd *cobra.Command) error {
+	err := rootCmd.ExecuteContext(ctx)
+	finishMetrics(rootCmd.ErrOrStderr())
+	if err == nil {
+		return nil
+	}
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) {
+		return usageError(err)
+	}
+	return err
+}
+
+// setup runs before every command, once the flags are parsed
+func setup(cmd *cobra.Command, args []string) error {
+	// logs follow the flags until a command loads the config, see loadConfig
+	setupLogging(config.Default().WithLogLevel(logLevel).WithLogFormat(logFormat), cmd.ErrOrStderr())
+	if err := setupProgress(cmd, args); err != nil {
+		return err
+	}
+	return setupMetrics(cmd, args)
+}
+
+func newStatsCmd(cfg config.Config) *cobra.Command {
 	var statsCmd = &cobra.Command{
 		Use:     "stats",
 		Short:   "Generate Recipes statistics based on specified parameters",
-		Run:     runStats,
+		RunE:    runE(runStats),
 		Example: `./parser stats --file ./files/test.json --postcode 10120 --words Potato,Mushroom,Veggie --fromTime 10AM --toTime 3PM`,
 	}
 
-	// get default values from config
-	cfg, err := config.ReadConfig()
+	addStatsFlags(statsCmd, cfg)
+	statsCmd.Flags().BoolVarP(&helpFlag, "help", "h", false, "Show help information")
+
+	return statsCmd
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if helpFlag {
+		cmd.Help()
+		return nil
+	}
+
+	// NOTE: config uses the builder pattern
+	// defaults < config file < env < flags, only flags set on the command line override
+	cfg, _, err := loadConfig(cmd)
 	if err != nil {
+		return inputError(errors.Wrap(err, "failed to read config"))
+	}
+
+	// sanitize parameters, every problem is reported before parsing starts
+	if err := cfg.Validate(); err != nil {
 		return err
 	}
+	log.Info().Msg("Calculating stats...")
 
-	statsCmd.Flags().StringVarP(&fileName, "file", "f", cfg.File, "File to use (optional)")
-	statsCmd.Flags().StringVarP(&fromTime, "fromTime", "s", cfg.FromTime, "From time (optional)")
-	statsCmd.Flags().StringVarP(&toTime, "toTime", "e", cfg.ToTime, "To time (optional)")
-	statsCmd.Flags().StringVarP(&postcode, "postcode", "p", cfg.Postcode, "Postcode (required)")
-	statsCmd.Flags().StringVarP(&words, "words", "w", strings.Join(cfg.Words, ","), "List of comma-separated words (optional)")
-	statsCmd.Flags().BoolVarP(&helpFlag, "help", "h", false, "Show help information")
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
 
-	if err := statsCmd.Execute(); err != nil {
-		return err
+	if len(cfg.Profile) > 1 {
+		data, err := profileStats(ctx, cmd, cfg)
+		if err != nil {
+			return errors.Wrap(err, "failed to generate stats")
+		}
+		return printJSON(cmd.OutOrStdout(), data)
 	}
 
-	return nil
-}
+	if cfg.State != "" {
+		data, err := incrementalStats(ctx, cfg)
+		if err != nil {
+			return errors.Wrap(err, "failed to generate stats")
+		}
+		return printJSON(cmd.OutOrStdout(), data)
+	}
 
-func runStats(cmd *cobra.Command, args []string) {
-	log.Info().Msg("Calculating stats...")
-	if helpFlag {
-		cmd.Help()
-		return
-	}
-
-	// sanitize parameters
-	// postcode is less than 10 chars
-	if len(postcode) > 10 || len(postcode) == 0 {
-		fmt.Println("postcode must be less than 10 characters")
-		return
-	}
-	// trim whitespace
-	tirmmedWords := strings.TrimSpace(words)
-	wordSlice := strings.Split(tirmmedWords, ",")
-	// remove empty strings
-	var words []string
-	for _, word := range wordSlice {
-		if word != "" {
-			words = append(words, word)
+	// Create JsonParser object - it implements the Parser interface
+	p := newParser(cfg)
+	go p.Parse(ctx)
+
+	if cfg.Approximate {
+		data, err := stats.NewApproxStats(p, cfg).WithMetrics(collector).Generate(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to generate stats")
 		}
+		return printJSON(cmd.OutOrStdout(), data)
 	}
 
-	// Additional logic can be added to process the parameters as needed
-	cfg, err := config.ReadConfig()
+	// Create stats object
+	s := stats.NewJsonStats(p, cfg).WithMetrics(collector)
+	// with a postcode filter the crosstab is filled in the same pass
+	if cfg.Crosstab && len(cfg.CrosstabPostcodes) > 0 {
+		s = s.WithCrosstab(stats.NewCrosstab(cfg.CrosstabPostcodes, cfg.CrosstabTopRecipes))
+	}
+	// generate stats
+	data, err := s.Generate(ctx)
 	if err != nil {
-		fmt.Println("Error reading config:", err)
-		return
+		return errors.Wrap(err, "failed to generate stats")
 	}
-	// NOTE: config uses the builder pattern
-	// Check if optional parameters are set, if yes, override the default values
-	if fileName != cfg.File {
-		cfg = cfg.WithFile(fileName)
+
+	// without a filter the busiest postcodes are only known after the first pass,
+	// so the file is read a second time counting recipes for those postcodes only
+	if cfg.Crosstab && len(cfg.CrosstabPostcodes) == 0 {
+		log.Info().Msg("Calculating crosstab for the busiest postcodes...")
+		ct := stats.NewCrosstab(s.TopPostcodes(cfg.CrosstabTopPostcodes), cfg.CrosstabTopRecipes)
+		p := newParser(cfg)
+		go p.Parse(ctx)
+		if err := ct.Count(ctx, p); err != nil {
+			return errors.Wrap(err, "failed to generate crosstab")
+		}
+		data.Crosstab = ct.Result()
 	}
-	if fromTime != cfg.FromTime {
-		cfg = cfg.WithFromTime(fromTime)
+
+	return printJSON(cmd.OutOrStdout(), data)
+}
+
+// profileStats runs every selected profile in one pass over the input
+func profileStats(ctx context.Context, cmd *cobra.Command, cfg config.Config) (map[string]stats.ResponseData, error) {
+	configs, err := profileConfigs(cmd, cfg)
+	if err != nil {
+		return nil, err
 	}
-	if toTime != cfg.ToTime {
-		cfg = cfg.WithToTime(toTime)
+	p := newParser(cfg)
+	go p.Parse(ctx)
+	return stats.NewProfileStats(p, configs).WithMetrics(collector).Generate(ctx)
+}
+
+// incrementalStats continues from the persisted state, parsing only the input files
+// it does not cover yet, and saves the updated state
+func incrementalStats(ctx context.Context, cfg config.Config) (stats.ResponseData, error) {
+	paths, err := parser.InputFiles(cfg.File)
+	if err != nil {
+		return stats.ResponseData{}, err
 	}
-	if postcode != cfg.Postcode {
-		cfg = cfg.WithPostcode(postcode)
+	// the state file may live next to the input files, it is not an input itself
+	paths = slices.DeleteFunc(paths, func(path string) bool {
+		return filepath.Clean(path) == filepath.Clean(cfg.State)
+	})
+	files, err := stats.StatInputFiles(paths)
+	if err != nil {
+		return stats.ResponseData{}, err
 	}
-	if len(words) > 0 {
-		cfg = cfg.WithWords(words)
+
+	pending := files
+	state, err := stats.LoadState(cfg.State)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Msg("ignoring state, recomputing all input files")
+		}
+		state = stats.NewState(cfg)
+	} else if pending, err = state.Pending(cfg, files); err != nil {
+		log.Warn().Err(err).Msg("state is outdated, recomputing all input files")
+		state, pending = stats.NewState(cfg), files
 	}
 
-	// Create JsonParser object - it implements the Parser interface
-	p := parser.NewJsonParser(cfg)
-	go p.Parse()
-	// Create stats object
-	s := stats.NewJsonStats(p, cfg)
-	// generate stats
-	data, err := s.Generate()
+	// the deduper remembers the records of the processed files, to drop their duplicates
+	deduper, err := state.Deduper(cfg)
 	if err != nil {
-		fmt.Println("Error generating stats:", err)
-		return
+		log.Warn().Err(err).Msg("ignoring state, recomputing all input files")
+		state, pending = stats.NewState(cfg), files
+		if deduper, err = state.Deduper(cfg); err != nil {
+			return stats.ResponseData{}, err
+		}
+	}
+	// the recipes of the state keep their names, later variants are grouped under them
+	normalizer := sharedNormalizer(cfg, state.RecipeNames()...)
+	s := stats.NewJsonStats(nil, cfg).WithState(state).WithMetrics(collector)
+	for _, f := range pending {
+		log.Info().Str("file", f.Path).Msg("Processing input file...")
+		p := newParser(cfg.WithFile(f.Path)).WithNormalizer(normalizer).WithDeduper(deduper)
+		go p.Parse(ctx)
+		if _, err := s.WithParser(p).Generate(ctx); err != nil {
+			return stats.ResponseData{}, err
+		}
+		state.MarkProcessed(f)
+	}
+
+	if err := state.KeepDeduper(deduper); err != nil {
+		return stats.ResponseData{}, err
+	}
+	if err := state.Save(cfg.State); err != nil {
+		return stats.ResponseData{}, errors.Wrap(err, "failed to save state")
 	}
+	log.Info().Int("processed", len(pending)).Int("skipped", len(files)-len(pending)).Msg("State saved")
 
+	return s.Response(), nil
+}
+
+// commandContext returns the context of a command run, it is cancelled on
+// SIGINT/SIGTERM and once --timeout expires. Cancelling it stops the parser.
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// printJSON prints the result as indented JSON
+func printJSON(w io.Writer, data any) error {
 	// Marshal the ResponseData to JSON
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		fmt.Println("Error marshaling JSON:", err)
-		return
+		return errors.Wrap(err, "failed to marshal JSON")
 	}
 
 	// print the result
-	fmt.Println(string(jsonData)) // this is piped to stdout
-	log.Info().Msg("Done!")       // this is piped to stderr
+	fmt.Fprintln(w, string(jsonData)) // this is piped to stdout
+	log.Info().Msg("Done!")           // this is piped to stderr
+	return nil
 }
@@ -0,0 +1,105 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/stats"
+)
+
+const testFile = "../../pkg/stats/testdata/test.json"
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+	missing := filepath.Join(dir, "missing.json")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{name: "success", args: []string{"stats", "--file", testFile}, expected: ExitOK},
+		{name: "unknown command", args: []string{"statistics"}, expected: ExitUsage},
+		{name: "unknown flag", args: []string{"stats", "--colour"}, expected: ExitUsage},
+		{name: "missing argument", args: []string{"diff", testFile}, expected: ExitUsage},
+		{name: "invalid progress", args: []string{"stats", "--file", testFile, "--progress", "sometimes"}, expected: ExitUsage},
+		{name: "pprof without address", args: []string{"stats", "--file", testFile, "--pprof"}, expected: ExitUsage},
+		{name: "metrics server", args: []string{"stats", "--file", testFile, "--metrics-addr", "127.0.0.1:0", "--pprof"}, expected: ExitOK},
+		{name: "invalid format", args: []string{"config", "show", "--format", "yaml"}, expected: ExitUsage},
+		{name: "missing config file", args: []string{"stats", "--file", testFile, "--config", missing}, expected: ExitInput},
+		{name: "missing input file", args: []string{"stats", "--file", missing}, expected: ExitValidation},
+		{name: "empty input file", args: []string{"stats", "--file", empty}, expected: ExitInput},
+		{name: "missing diff file", args: []string{"diff", testFile, missing}, expected: ExitInput},
+		{name: "invalid postcode", args: []string{"stats", "--file", testFile, "--postcode", "!"}, expected: ExitValidation},
+		{name: "invalid time window", args: []string{"stats", "--file", testFile, "--fromTime", "3PM", "--toTime", "10AM"}, expected: ExitValidation},
+		{name: "timeout", args: []string{"stats", "--file", testFile, "--timeout", "1ns"}, expected: ExitTimeout},
+		{name: "generate", args: []string{"generate", "--records", "10", "--malformed", "empty_recipe=0.5"}, expected: ExitOK},
+		{name: "generate invalid weight", args: []string{"generate", "--weekdays", "Monday=often"}, expected: ExitUsage},
+		{name: "generate invalid rate", args: []string{"generate", "--malformed", "empty_recipe=2"}, expected: ExitValidation},
+		{name: "state not writable", args: []string{"stats", "--file", testFile, "--state", filepath.Join(missing, "state.json")}, expected: ExitInternal},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := run(t, tt.args...)
+			if got := ExitCode(err); got != tt.expected {
+				t.Errorf("Expected %v, but got %v (%v)", tt.expected, got, err)
+			}
+		})
+	}
+}
+
+func TestInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rootCmd := newRootCmd()
+	rootCmd.SetArgs([]string{"stats", "--file", testFile})
+	rootCmd.SetOut(io.Discard)
+	if got := ExitCode(execute(ctx, rootCmd)); got != ExitInterrupted {
+		t.Errorf("Expected %v, but got %v", ExitInterrupted, got)
+	}
+}
+
+func TestStdoutOnlyCarriesStats(t *testing.T) {
+	out, err := run(t, "stats", "--file", testFile)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	var data stats.ResponseData
+	if err := json.Unmarshal(out, &data); err != nil {
+		t.Errorf("Expected JSON on stdout, but got %v: %q", err, out)
+	}
+
+	out, err = run(t, "stats", "--file", testFile, "--postcode", "!")
+	if err == nil {
+		t.Fatalf("Expected an error, but got nil")
+	}
+	if len(out) != 0 {
+		t.Errorf("Expected empty stdout, but got %q", out)
+	}
+}
+
+// run executes the command line in-process and returns its stdout
+func run(t *testing.T, args ...string) ([]byte, error) {
+	t.Helper()
+	t.Setenv("CONFIG", "")
+
+	var stdout bytes.Buffer
+	rootCmd := newRootCmd()
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(&stdout)
+	err := execute(context.Background(), rootCmd)
+
+	return stdout.Bytes(), err
+}
@@ -0,0 +1,164 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+	"github.com/rashad-j/jsonreader/pkg/stats"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	trendFormat string
+
+	// datePattern matches 2024-01-22 or 20240122 in a file name
+	datePattern = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})`)
+)
+
+func newTrendCmd(cfg config.Config) *cobra.Command {
+	var trendCmd = &cobra.Command{
+		Use:   "trend DIR",
+		Short: "Calculate stats per daily export and output them as time series",
+		Long: `Calculate stats for every .json file of DIR whose name contains a date
+(e.g. 2024-01-22.json or fixtures-20240122.json) and output time series, ordered by
+date, of the unique recipe count, the count per recipe and the postcode/time window count.`,
+		Args:    cobra.ExactArgs(1),
+		RunE:    runE(runTrend),
+		Example: `./parser trend ./exports --postcode 10120 --fromTime 10AM --toTime 3PM --format csv`,
+	}
+
+	addQueryFlags(trendCmd, cfg)
+	trendCmd.Flags().StringVar(&trendFormat, "format", "json", "Output format, json or csv (optional)")
+
+	return trendCmd
+}
+
+func runTrend(cmd *cobra.Command, args []string) error {
+	if trendFormat != "json" && trendFormat != "csv" {
+		return usageError(errors.Errorf("invalid format %q, must be json or csv", trendFormat))
+	}
+
+	cfg, _, err := loadConfig(cmd)
+	if err != nil {
+		return inputError(errors.Wrap(err, "failed to read config"))
+	}
+	if err := cfg.ValidateQuery(); err != nil {
+		return err
+	}
+
+	files, err := datedFiles(args[0])
+	if err != nil {
+		return inputError(errors.Wrap(err, "failed to list files"))
+	}
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	normalizer := sharedNormalizer(cfg)
+	points := make([]stats.TrendPoint, 0, len(files))
+	for _, f := range files {
+		log.Info().Str("file", f.path).Str("date", f.date).Msg("Calculating stats...")
+		p := newParser(cfg.WithFile(f.path)).WithNormalizer(normalizer)
+		go p.Parse(ctx)
+		data, err := stats.NewJsonStats(p, cfg).WithMetrics(collector).Generate(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to generate stats for %s", f.path)
+		}
+		points = append(points, stats.TrendPoint{Date: f.date, Data: data})
+	}
+
+	trend := stats.Trend(points)
+	if trendFormat == "csv" {
+		return errors.Wrap(writeTrendCSV(cmd.OutOrStdout(), trend), "failed to write CSV")
+	}
+	return printJSON(cmd.OutOrStdout(), trend)
+}
+
+type datedFile struct {
+	path string
+	date string
+}
+
+// datedFiles returns the .json files of dir with a date in their name, ordered by date
+func datedFiles(dir string) ([]datedFile, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to stat directory")
+	}
+	if !info.IsDir() {
+		return nil, errors.Errorf("%s is not a directory", dir)
+	}
+	paths, err := parser.InputFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []datedFile
+	seen := make(map[string]string)
+	for _, path := range paths {
+		match := datePattern.FindStringSubmatch(filepath.Base(path))
+		if match == nil {
+			log.Warn().Str("file", path).Msg("skipping file without a date in its name")
+			continue
+		}
+		date, err := time.Parse("2006-01-02", match[1]+"-"+match[2]+"-"+match[3])
+		if err != nil {
+			log.Warn().Str("file", path).Msg("skipping file with an invalid date in its name")
+			continue
+		}
+		day := date.Format("2006-01-02")
+		if other, ok := seen[day]; ok {
+			return nil, errors.Errorf("%s and %s have the same date %s", other, path, day)
+		}
+		seen[day] = path
+		files = append(files, datedFile{path: path, date: day})
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no dated .json files in %s", dir)
+	}
+	slices.SortFunc(files, func(a, b datedFile) int {
+		return strings.Compare(a.date, b.date)
+	})
+
+	return files, nil
+}
+
+// writeTrendCSV writes one row per date, with a column per series and recipe
+func writeTrendCSV(w io.Writer, trend stats.TrendData) error {
+	recipes := make([]string, 0, len(trend.CountPerRecipe))
+	for recipe := range trend.CountPerRecipe {
+		recipes = append(recipes, recipe)
+	}
+	slices.Sort(recipes)
+
+	window := trend.CountPerPostcodeAndTime
+	writer := csv.NewWriter(w)
+	header := []string{"date", "unique_recipe_count", fmt.Sprintf("deliveries_%s_%s_%s", window.Postcode, window.From, window.To)}
+	if err := writer.Write(append(header, recipes...)); err != nil {
+		return err
+	}
+	for i, date := range trend.Dates {
+		row := []string{date, strconv.Itoa(trend.UniqueRecipeCount[i]), strconv.Itoa(window.DeliveryCount[i])}
+		for _, recipe := range recipes {
+			row = append(row, strconv.Itoa(trend.CountPerRecipe[recipe][i]))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
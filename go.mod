@@ -3,14 +3,17 @@ module github.com/rashad-j/jsonreader
 go 1.21.5
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/caarlos0/env v3.5.0+incompatible
+	github.com/mattn/go-isatty v0.0.19
 	github.com/rs/zerolog v1.31.0
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.19 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/sys v0.12.0 // indirect
 )
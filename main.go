@@ -1,13 +1,14 @@
 package main
 
 import (
-	"github.com/rs/zerolog/log"
+	"os"
 
 	"github.com/rashad-j/jsonreader/cmd/stats"
 )
 
 func main() {
-	if err := stats.ExecuteStatsCMD(); err != nil {
-		log.Fatal().Err(err).Msg("failed to execute stats command")
-	}
+	err := stats.ExecuteStatsCMD()
+	// stdout only carries the stats, errors go to stderr
+	stats.PrintError(os.Stderr, err)
+	os.Exit(stats.ExitCode(err))
 }
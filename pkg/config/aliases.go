@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// Aliases maps variant recipe names to their canonical name, e.g. the names of a
+// renamed recipe, so their records are counted under one name
+type Aliases map[string]string
+
+// LoadAliases reads an alias file, a YAML, JSON or TOML map of canonical recipe
+// names to the list of their aliases:
+//
+//	Speedy Steak Fajitas:
+//	  - Speedy Steak Fajitas 2.0
+func LoadAliases(path string) (Aliases, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read alias file")
+	}
+	_, normalized, err := decodeFile(path, content)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse alias file")
+	}
+	var canonical map[string][]string
+	if err := json.Unmarshal(normalized, &canonical); err != nil {
+		return nil, errors.Wrap(err, "invalid alias file, expected canonical names with lists of aliases")
+	}
+	return NewAliases(canonical)
+}
+
+// NewAliases inverts canonical names with their aliases. An alias of two canonical
+// names, or a canonical name that is an alias itself, is an error, so every name
+// resolves in one step.
+func NewAliases(canonical map[string][]string) (Aliases, error) {
+	names := make([]string, 0, len(canonical))
+	for name := range canonical {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	aliases := make(Aliases)
+	for _, name := range names {
+		if name == "" {
+			return nil, errors.New("empty canonical name")
+		}
+		for _, alias := range canonical[name] {
+			if alias == "" {
+				return nil, errors.Errorf("empty alias of %q", name)
+			}
+			if other, ok := aliases[alias]; ok && other != name {
+				return nil, errors.Errorf("%q is an alias of both %q and %q", alias, other, name)
+			}
+			if alias != name {
+				aliases[alias] = name
+			}
+		}
+	}
+	for _, name := range names {
+		if canonicalName, ok := aliases[name]; ok {
+			return nil, errors.Errorf("%q is a canonical name and an alias of %q", name, canonicalName)
+		}
+	}
+	return aliases, nil
+}
+
+// Names returns the aliases and the canonical names, sorted
+func (a Aliases) Names() []string {
+	seen := make(map[string]bool, len(a))
+	names := make([]string, 0, len(a))
+	for alias, name := range a {
+		for _, n := range []string{alias, name} {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// LoadAliases reads the alias file of the config, the aliases are nil without one
+func (c Config) LoadAliases() (Aliases, error) {
+	if c.Aliases == "" {
+		return nil, nil
+	}
+	return LoadAliases(c.Aliases)
+}
+
+func (c Config) validateAliases() ValidationErrors {
+	var errs ValidationErrors
+	if _, err := c.LoadAliases(); err != nil {
+		errs = append(errs, ValidationError{Key: "aliases", Value: c.Aliases, Reason: errors.Cause(err).Error()})
+	}
+	if c.ShowAliases && c.Aliases == "" {
+		errs = append(errs, ValidationError{Key: "show_aliases", Value: c.ShowAliases, Reason: "requires an alias file"})
+	}
+	if c.ShowAliases && c.Approximate {
+		errs = append(errs, ValidationError{Key: "show_aliases", Value: c.ShowAliases, Reason: "is not supported in approximate mode"})
+	}
+	return errs
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadAliases(t *testing.T) {
+	expected := Aliases{"Speedy Steak Fajitas 2.0": "Speedy Steak Fajitas", "Speedy Fajitas": "Speedy Steak Fajitas", "Dill Chicken": "Creamy Dill Chicken"}
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected Aliases
+		err      bool
+	}{
+		{
+			name:     "yaml",
+			file:     "aliases.yaml",
+			content:  "Speedy Steak Fajitas:\n  - Speedy Steak Fajitas 2.0\n  - Speedy Fajitas\nCreamy Dill Chicken: [Dill Chicken]\n",
+			expected: expected,
+		},
+		{
+			name:     "json",
+			file:     "aliases.json",
+			content:  `{"Speedy Steak Fajitas": ["Speedy Steak Fajitas 2.0", "Speedy Fajitas"], "Creamy Dill Chicken": ["Dill Chicken"]}`,
+			expected: expected,
+		},
+		{
+			name:     "toml",
+			file:     "aliases.toml",
+			content:  "\"Speedy Steak Fajitas\" = [\"Speedy Steak Fajitas 2.0\", \"Speedy Fajitas\"]\n\"Creamy Dill Chicken\" = [\"Dill Chicken\"]\n",
+			expected: expected,
+		},
+		{
+			name:     "a name is not its own alias",
+			file:     "aliases.yaml",
+			content:  "Creamy Dill Chicken: [Creamy Dill Chicken, Dill Chicken]\n",
+			expected: Aliases{"Dill Chicken": "Creamy Dill Chicken"},
+		},
+		{name: "alias of two names", file: "aliases.yaml", content: "A: [C]\nB: [C]\n", err: true},
+		{name: "chained alias", file: "aliases.yaml", content: "A: [B]\nB: [C]\n", err: true},
+		{name: "empty alias", file: "aliases.yaml", content: "A: [\"\"]\n", err: true},
+		{name: "not a list", file: "aliases.yaml", content: "A: B\n", err: true},
+		{name: "unsupported extension", file: "aliases.txt", content: "A: [B]\n", err: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadAliases(writeConfigFile(t, tt.file, tt.content))
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, but got %v", tt.err, err)
+			}
+			if !tt.err && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAliases_Names(t *testing.T) {
+	aliases := Aliases{"Speedy Fajitas": "Speedy Steak Fajitas", "Speedy Steak Fajitas 2.0": "Speedy Steak Fajitas"}
+	expected := []string{"Speedy Fajitas", "Speedy Steak Fajitas", "Speedy Steak Fajitas 2.0"}
+	if got := aliases.Names(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
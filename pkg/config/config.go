@@ -2,16 +2,102 @@ package config
 
 import "github.com/caarlos0/env"
 
+// Config holds the settings of a run. Values are layered, see Load: defaults <
+// config file < environment variables < command line flags.
 type Config struct {
-	File     string   `env:"FILE" envDefault:"/app/files/fixtures.json"`
-	Words    []string `env:"WORDS" envDefault:"Potato,Mushroom,Veggie"`
-	Postcode string   `env:"POSTCODE" envDefault:"10120"`
-	FromTime string   `env:"FROM" envDefault:"10AM"`
-	ToTime   string   `env:"TO" envDefault:"3PM"`
+	// File is a fixtures file, or a directory whose .json files are read in name order
+	File string `json:"file" env:"FILE"`
+	// Engine is the parser of the input files: json (encoding/json) or fast (a
+	// memory-mapped scanner for the recipe objects), see parser.NewJsonParser
+	Engine string `json:"engine" env:"ENGINE"`
+	// FieldMapping locates the recipe fields in the exports of other markets
+	FieldMapping FieldMapping `json:"fields" env:"FIELDS"`
+	// Extended reads the optional fields of the records, e.g. box_size and price,
+	// and adds box unit and revenue stats
+	Extended bool     `json:"extended" env:"EXTENDED"`
+	Words    []string `json:"words" env:"WORDS"`
+	Postcode string   `json:"postcode" env:"POSTCODE"`
+	FromTime string   `json:"from" env:"FROM"`
+	ToTime   string   `json:"to" env:"TO"`
+
+	// Normalize cleans up recipe names and postcodes before they are checked and
+	// counted, NormalizePostcodeWidth pads numeric postcodes with zeros to its width
+	Normalize              bool `json:"normalize" env:"NORMALIZE"`
+	NormalizePostcodeWidth int  `json:"normalize_postcode_width" env:"NORMALIZE_POSTCODE_WIDTH"`
+
+	// Aliases is a file mapping variant recipe names to canonical ones, see
+	// LoadAliases. ShowAliases lists the aliases counted under each recipe.
+	Aliases     string `json:"aliases" env:"ALIASES"`
+	ShowAliases bool   `json:"show_aliases" env:"SHOW_ALIASES"`
+
+	// Dedupe drops the records repeating the postcode, recipe, delivery and, with
+	// Extended, the customer_id of an earlier record. The records are remembered in
+	// a Bloom filter sized for DedupeCapacity records at the DedupeFalsePositive rate.
+	Dedupe              bool    `json:"dedupe" env:"DEDUPE"`
+	DedupeCapacity      int     `json:"dedupe_capacity" env:"DEDUPE_CAPACITY"`
+	DedupeFalsePositive float64 `json:"dedupe_false_positive" env:"DEDUPE_FALSE_POSITIVE"`
+
+	// Rules are the checks records must pass to be counted, only set in the config file
+	Rules Rules `json:"rules"`
+
+	// State is a file persisting the aggregation state, so the next run only
+	// processes new input files
+	State string `json:"state" env:"STATE"`
+
+	// Crosstab enables the recipe-by-postcode breakdown. When CrosstabPostcodes
+	// is empty the CrosstabTopPostcodes busiest postcodes are used instead.
+	Crosstab             bool     `json:"crosstab" env:"CROSSTAB"`
+	CrosstabPostcodes    []string `json:"crosstab_postcodes" env:"CROSSTAB_POSTCODES"`
+	CrosstabTopPostcodes int      `json:"crosstab_top_postcodes" env:"CROSSTAB_TOP_POSTCODES"`
+	CrosstabTopRecipes   int      `json:"crosstab_top_recipes" env:"CROSSTAB_TOP_RECIPES"`
+
+	// Approximate replaces the exact maps with sketches of fixed size, see pkg/sketch
+	Approximate     bool    `json:"approximate" env:"APPROXIMATE"`
+	ApproxPrecision int     `json:"approx_precision" env:"APPROX_PRECISION"`
+	ApproxEpsilon   float64 `json:"approx_epsilon" env:"APPROX_EPSILON"`
+	ApproxDelta     float64 `json:"approx_delta" env:"APPROX_DELTA"`
+	ApproxTop       int     `json:"approx_top" env:"APPROX_TOP"`
+
+	// Profiles are named queries defined in the config file, Profile selects the
+	// ones to run, see ApplyProfile
+	Profiles map[string]Profile `json:"profiles"`
+	Profile  []string           `json:"profile" env:"PROFILE"`
+
+	// LogLevel and LogFormat (console or json) configure the logs on stderr.
+	// LogRejections is the number of rejected records logged per second, the
+	// others are only counted in the summary at the end of parsing.
+	LogLevel      string `json:"log_level" env:"LOG_LEVEL"`
+	LogFormat     string `json:"log_format" env:"LOG_FORMAT"`
+	LogRejections int    `json:"log_rejections" env:"LOG_REJECTIONS"`
+}
+
+// Default returns the built-in defaults, the lowest layer of the config
+func Default() Config {
+	return Config{
+		File:                 "/app/files/fixtures.json",
+		Engine:               "json",
+		Words:                []string{"Potato", "Mushroom", "Veggie"},
+		Postcode:             "10120",
+		FromTime:             "10AM",
+		ToTime:               "3PM",
+		DedupeCapacity:       10_000_000,
+		DedupeFalsePositive:  0.0001,
+		Rules:                DefaultRules(),
+		CrosstabTopPostcodes: 10,
+		CrosstabTopRecipes:   10,
+		ApproxPrecision:      14,
+		ApproxEpsilon:        0.0001,
+		ApproxDelta:          0.01,
+		ApproxTop:            10,
+		LogLevel:             "info",
+		LogFormat:            "console",
+		LogRejections:        10,
+	}
 }
 
+// ReadConfig returns the defaults overridden by environment variables
 func ReadConfig() (Config, error) {
-	cfg := Config{}
+	cfg := Default()
 	err := env.Parse(&cfg)
 	return cfg, err
 }
@@ -21,6 +107,21 @@ func (c Config) WithFile(file string) Config {
 	return c
 }
 
+func (c Config) WithEngine(engine string) Config {
+	c.Engine = engine
+	return c
+}
+
+func (c Config) WithFieldMapping(fields FieldMapping) Config {
+	c.FieldMapping = fields
+	return c
+}
+
+func (c Config) WithExtended(enabled bool) Config {
+	c.Extended = enabled
+	return c
+}
+
 func (c Config) WithWords(words []string) Config {
 	c.Words = words
 	return c
@@ -40,3 +141,113 @@ func (c Config) WithToTime(toTime string) Config {
 	c.ToTime = toTime
 	return c
 }
+
+func (c Config) WithProfile(names []string) Config {
+	c.Profile = names
+	return c
+}
+
+func (c Config) WithNormalize(enabled bool) Config {
+	c.Normalize = enabled
+	return c
+}
+
+func (c Config) WithNormalizePostcodeWidth(width int) Config {
+	c.NormalizePostcodeWidth = width
+	return c
+}
+
+func (c Config) WithAliases(path string) Config {
+	c.Aliases = path
+	return c
+}
+
+func (c Config) WithShowAliases(enabled bool) Config {
+	c.ShowAliases = enabled
+	return c
+}
+
+func (c Config) WithDedupe(enabled bool) Config {
+	c.Dedupe = enabled
+	return c
+}
+
+func (c Config) WithDedupeCapacity(capacity int) Config {
+	c.DedupeCapacity = capacity
+	return c
+}
+
+func (c Config) WithDedupeFalsePositive(rate float64) Config {
+	c.DedupeFalsePositive = rate
+	return c
+}
+
+func (c Config) WithRules(rules Rules) Config {
+	c.Rules = rules
+	return c
+}
+
+func (c Config) WithState(state string) Config {
+	c.State = state
+	return c
+}
+
+func (c Config) WithCrosstab(enabled bool) Config {
+	c.Crosstab = enabled
+	return c
+}
+
+func (c Config) WithCrosstabPostcodes(postcodes []string) Config {
+	c.CrosstabPostcodes = postcodes
+	return c
+}
+
+func (c Config) WithCrosstabTopPostcodes(n int) Config {
+	c.CrosstabTopPostcodes = n
+	return c
+}
+
+func (c Config) WithCrosstabTopRecipes(n int) Config {
+	c.CrosstabTopRecipes = n
+	return c
+}
+
+func (c Config) WithApproximate(enabled bool) Config {
+	c.Approximate = enabled
+	return c
+}
+
+func (c Config) WithApproxPrecision(precision int) Config {
+	c.ApproxPrecision = precision
+	return c
+}
+
+func (c Config) WithApproxEpsilon(epsilon float64) Config {
+	c.ApproxEpsilon = epsilon
+	return c
+}
+
+func (c Config) WithApproxDelta(delta float64) Config {
+	c.ApproxDelta = delta
+	return c
+}
+
+func (c Config) WithApproxTop(n int) Config {
+	c.ApproxTop = n
+	return c
+}
+
+func (c Config) WithLogLevel(level string) Config {
+	c.LogLevel = level
+	return c
+}
+
+func (c Config) WithLogFormat(format string) Config {
+	c.LogFormat = format
+	return c
+}
+
+func (c Config) WithLogRejections(perSecond int) Config {
+	c.LogRejections = perSecond
+	return c
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FieldMapping locates the recipe fields in the input records as JSON pointers
+// (RFC 6901), e.g. /meal_name or /address/postcode. An empty path keeps the default
+// key of the field. Like the default keys, pointer keys match ignoring case. It
+// applies to JSON-array input, the parser has no NDJSON reader.
+type FieldMapping struct {
+	Recipe   string `json:"recipe,omitempty"`
+	Postcode string `json:"postcode,omitempty"`
+	Delivery string `json:"delivery,omitempty"`
+}
+
+// fieldNames are the recipe fields in the order of FieldMapping.Paths
+var fieldNames = []string{"recipe", "postcode", "delivery"}
+
+// ParseFieldMapping reads a mapping like postcode=/zip,recipe=/meal_name
+func ParseFieldMapping(text string) (FieldMapping, error) {
+	var m FieldMapping
+	for _, pair := range strings.Split(text, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(pair, "=")
+		if !ok {
+			return FieldMapping{}, errors.Errorf("invalid field mapping %q, must be field=/path", pair)
+		}
+		switch strings.TrimSpace(name) {
+		case "recipe":
+			m.Recipe = strings.TrimSpace(path)
+		case "postcode":
+			m.Postcode = strings.TrimSpace(path)
+		case "delivery":
+			m.Delivery = strings.TrimSpace(path)
+		default:
+			return FieldMapping{}, errors.Errorf("unknown field %q, must be one of %s", name, strings.Join(fieldNames, ", "))
+		}
+	}
+	return m, nil
+}
+
+// UnmarshalText reads the FIELDS environment variable, see ParseFieldMapping
+func (m *FieldMapping) UnmarshalText(text []byte) error {
+	mapping, err := ParseFieldMapping(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mapping
+	return nil
+}
+
+// UnmarshalJSON reads the mapping of a config file, either an object of paths or
+// a string like the FIELDS environment variable
+func (m *FieldMapping) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return m.UnmarshalText([]byte(text))
+	}
+	// the alias has no methods, so the object is decoded field by field
+	type paths FieldMapping
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode((*paths)(m))
+}
+
+// String formats the mapping like ParseFieldMapping reads it
+func (m FieldMapping) String() string {
+	var pairs []string
+	for i, pointer := range []string{m.Recipe, m.Postcode, m.Delivery} {
+		if pointer != "" {
+			pairs = append(pairs, fieldNames[i]+"="+pointer)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// IsDefault reports whether every field is read from its default key
+func (m FieldMapping) IsDefault() bool {
+	return m == FieldMapping{}
+}
+
+// Paths returns the keys leading to the recipe, postcode and delivery fields
+func (m FieldMapping) Paths() ([3][]string, error) {
+	var paths [3][]string
+	for i, pointer := range []string{m.Recipe, m.Postcode, m.Delivery} {
+		if pointer == "" {
+			paths[i] = []string{fieldNames[i]}
+			continue
+		}
+		tokens, err := ParsePointer(pointer)
+		if err != nil {
+			return paths, errors.Wrapf(err, "invalid path of %s", fieldNames[i])
+		}
+		paths[i] = tokens
+	}
+
+	// a field inside another one cannot be read
+	for i := range paths {
+		for j := range paths {
+			if i != j && len(paths[i]) <= len(paths[j]) && equalFold(paths[i], paths[j][:len(paths[i])]) {
+				return paths, errors.Errorf("%s and %s have overlapping paths", fieldNames[i], fieldNames[j])
+			}
+		}
+	}
+	return paths, nil
+}
+
+func equalFold(a, b []string) bool {
+	return slices.EqualFunc(a, b, strings.EqualFold)
+}
+
+// ParsePointer splits a JSON pointer like /address/postcode into its keys
+func ParsePointer(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.Errorf("%q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		// ~1 is /, ~0 is ~, any other ~ is invalid
+		if strings.Contains(strings.NewReplacer("~0", "", "~1", "").Replace(token), "~") {
+			return nil, errors.Errorf("%q has an invalid escape, use ~0 for ~ and ~1 for /", pointer)
+		}
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// validateFields checks the field mapping
+func (c Config) validateFields() ValidationErrors {
+	if _, err := c.FieldMapping.Paths(); err != nil {
+		return ValidationErrors{{Key: "fields", Value: c.FieldMapping.String(), Reason: err.Error()}}
+	}
+	return nil
+}
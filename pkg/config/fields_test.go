@@ -0,0 +1,98 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		name     string
+		pointer  string
+		expected []string
+		err      bool
+	}{
+		{name: "key", pointer: "/zip", expected: []string{"zip"}},
+		{name: "nested key", pointer: "/address/postcode", expected: []string{"address", "postcode"}},
+		{name: "array index", pointer: "/slots/0", expected: []string{"slots", "0"}},
+		{name: "escaped slash and tilde", pointer: "/a~1b/c~0d", expected: []string{"a/b", "c~d"}},
+		{name: "escapes apply once", pointer: "/~01", expected: []string{"~1"}},
+		{name: "empty key", pointer: "/", expected: []string{""}},
+		{name: "no leading slash", pointer: "zip", err: true},
+		{name: "invalid escape", pointer: "/a~2b", err: true},
+		{name: "trailing tilde", pointer: "/a~", err: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePointer(tt.pointer)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, but got %v", tt.err, err)
+			}
+			if !tt.err && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFieldMapping_Paths(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected [3][]string
+		err      bool
+	}{
+		{name: "default keys", text: "", expected: [3][]string{{"recipe"}, {"postcode"}, {"delivery"}}},
+		{
+			name:     "renamed and nested fields",
+			text:     "postcode=/address/zip, recipe=/meal_name",
+			expected: [3][]string{{"meal_name"}, {"address", "zip"}, {"delivery"}},
+		},
+		{name: "unknown field", text: "zip=/zip", err: true},
+		{name: "missing path", text: "postcode", err: true},
+		{name: "field inside another", text: "postcode=/Address,delivery=/address/slot", err: true},
+		{name: "same path", text: "postcode=/id,recipe=/id", err: true},
+		{name: "default key inside a path", text: "postcode=/recipe/zip", err: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			mapping, err := ParseFieldMapping(tt.text)
+			var got [3][]string
+			if err == nil {
+				got, err = mapping.Paths()
+			}
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, but got %v", tt.err, err)
+			}
+			if !tt.err && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFieldMapping_UnmarshalJSON(t *testing.T) {
+	expected := FieldMapping{Postcode: "/zip", Recipe: "/meal_name"}
+	for _, content := range []string{`{"postcode": "/zip", "recipe": "/meal_name"}`, `"postcode=/zip,recipe=/meal_name"`} {
+		var got FieldMapping
+		if err := json.Unmarshal([]byte(content), &got); err != nil {
+			t.Fatalf("Expected no error for %s, but got %v", content, err)
+		}
+		if got != expected {
+			t.Errorf("Expected %+v, but got %+v", expected, got)
+		}
+		if got.String() != "recipe=/meal_name,postcode=/zip" {
+			t.Errorf("Expected %v, but got %v", "recipe=/meal_name,postcode=/zip", got.String())
+		}
+	}
+
+	var got FieldMapping
+	if err := json.Unmarshal([]byte(`{"zip": "/zip"}`), &got); err == nil {
+		t.Errorf("Expected an error for an unknown field, but got %+v", got)
+	}
+}
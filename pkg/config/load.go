@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/caarlos0/env"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Source tells which layer a config value comes from
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceProfile Source = "profile"
+	SourceFlag    Source = "flag"
+)
+
+// Sources maps each config key (the json name of a field) to the layer its value comes from
+type Sources map[string]Source
+
+// Field is a config value with its key and source, see Fields
+type Field struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source Source `json:"source"`
+}
+
+// Load layers the defaults, the config file at path (if not empty) and the
+// environment variables, each overriding the previous one. Command line flags are
+// the last layer, applied by the caller, who records them in the returned Sources.
+func Load(path string) (Config, Sources, error) {
+	cfg := Default()
+	sources := make(Sources)
+	for _, key := range Keys() {
+		sources[key] = SourceDefault
+	}
+
+	if path != "" {
+		keys, err := readFile(path, &cfg)
+		if err != nil {
+			return Config{}, nil, err
+		}
+		for _, key := range keys {
+			sources[key] = SourceFile
+		}
+	}
+
+	env.OnEnvVarSet = func(field reflect.StructField, _ string) {
+		sources[fieldKey(field)] = SourceEnv
+	}
+	defer func() { env.OnEnvVarSet = nil }()
+	if err := env.Parse(&cfg); err != nil {
+		return Config{}, nil, errors.Wrap(err, "failed to read environment variables")
+	}
+
+	return cfg, sources, nil
+}
+
+// Keys returns the config keys in declaration order
+func Keys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		keys = append(keys, fieldKey(t.Field(i)))
+	}
+	return keys
+}
+
+// Fields returns every config value with its key and source, in declaration order
+func (c Config) Fields(sources Sources) []Field {
+	v := reflect.ValueOf(c)
+	fields := make([]Field, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		key := fieldKey(v.Type().Field(i))
+		fields = append(fields, Field{Key: key, Value: v.Field(i).Interface(), Source: sources[key]})
+	}
+	return fields
+}
+
+func fieldKey(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
+
+// readFile decodes a YAML, JSON or TOML config file onto cfg, chosen by the file
+// extension, and returns the keys it sets. Every format is decoded into a generic
+// map first and then into cfg by its json tags, so the keys are the same for all.
+func readFile(path string, cfg *Config) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+
+	values, normalized, err := decodeFile(path, content)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse config file")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	// typos in keys are reported instead of silently ignored
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, errors.Wrap(err, "invalid config file")
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// decodeFile decodes YAML, JSON or TOML content, chosen by the extension of path,
+// into a generic map and returns it also converted to JSON
+func decodeFile(path string, content []byte) (map[string]any, []byte, error) {
+	values := make(map[string]any)
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &values)
+	case ".json":
+		err = json.Unmarshal(content, &values)
+	case ".toml":
+		err = toml.Unmarshal(content, &values)
+	default:
+		return nil, nil, errors.Errorf("unsupported file extension %q, use .yaml, .yml, .json or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	normalized, err := json.Marshal(values)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to convert to JSON")
+	}
+	return values, normalized, nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "postcode: \"10121\"\nfrom: 9AM\nwords: [Chicken]\napprox_top: 0\n")
+	t.Setenv("FROM", "11AM")
+
+	cfg, sources, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	expected := Default().WithPostcode("10121").WithFromTime("11AM").WithWords([]string{"Chicken"}).WithApproxTop(0)
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, cfg)
+	}
+	expectedSources := map[string]Source{
+		"postcode":   SourceFile,
+		"from":       SourceEnv,
+		"words":      SourceFile,
+		"approx_top": SourceFile,
+		"to":         SourceDefault,
+		"file":       SourceDefault,
+	}
+	for key, source := range expectedSources {
+		if sources[key] != source {
+			t.Errorf("%s: expected source %s, but got %s", key, source, sources[key])
+		}
+	}
+}
+
+func TestLoad_Formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "yaml", file: "config.yml", content: "postcode: \"10122\"\ncrosstab: true\napprox_epsilon: 0.001\n"},
+		{name: "json", file: "config.json", content: `{"postcode": "10122", "crosstab": true, "approx_epsilon": 0.001}`},
+		{name: "toml", file: "config.toml", content: "postcode = \"10122\"\ncrosstab = true\napprox_epsilon = 0.001\n"},
+	}
+
+	expected := Default().WithPostcode("10122").WithCrosstab(true).WithApproxEpsilon(0.001)
+	for _, tt := range tests {
+		// avoid closure
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := Load(writeConfigFile(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if !reflect.DeepEqual(cfg, expected) {
+				t.Errorf("Expected %+v, but got %+v", expected, cfg)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "unknown key", file: "config.yaml", content: "postcod: \"10120\"\n"},
+		{name: "wrong type", file: "config.json", content: `{"approx_top": "ten"}`},
+		{name: "unsupported extension", file: "config.ini", content: "postcode=10120\n"},
+	}
+
+	for _, tt := range tests {
+		// avoid closure
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Load(writeConfigFile(t, tt.file, tt.content)); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+	return path
+}
@@ -0,0 +1,41 @@
+package config
+
+import "github.com/pkg/errors"
+
+// Profile is a named postcode, time window and words combination. Empty fields
+// keep the value of the config the profile is applied to.
+type Profile struct {
+	Postcode string   `json:"postcode"`
+	FromTime string   `json:"from"`
+	ToTime   string   `json:"to"`
+	Words    []string `json:"words"`
+}
+
+// ApplyProfile returns the config with the values of the named profile, and the
+// keys the profile sets
+func (c Config) ApplyProfile(name string) (Config, []string, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Config{}, nil, errors.Errorf("unknown profile %q", name)
+	}
+
+	var keys []string
+	if profile.Postcode != "" {
+		c = c.WithPostcode(profile.Postcode)
+		keys = append(keys, "postcode")
+	}
+	if profile.FromTime != "" {
+		c = c.WithFromTime(profile.FromTime)
+		keys = append(keys, "from")
+	}
+	if profile.ToTime != "" {
+		c = c.WithToTime(profile.ToTime)
+		keys = append(keys, "to")
+	}
+	if profile.Words != nil {
+		c = c.WithWords(profile.Words)
+		keys = append(keys, "words")
+	}
+
+	return c, keys, nil
+}
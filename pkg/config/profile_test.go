@@ -0,0 +1,60 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfig_ApplyProfile(t *testing.T) {
+	cfg := Default()
+	cfg.Profiles = map[string]Profile{
+		"berlin-lunch":  {Postcode: "10120", FromTime: "10AM", ToTime: "3PM", Words: []string{"Potato", "Veggie"}},
+		"postcode-only": {Postcode: "10121"},
+	}
+
+	tests := []struct {
+		name         string
+		profile      string
+		expected     Config
+		expectedKeys []string
+		wantErr      bool
+	}{
+		{
+			name:         "all fields",
+			profile:      "berlin-lunch",
+			expected:     cfg.WithPostcode("10120").WithFromTime("10AM").WithToTime("3PM").WithWords([]string{"Potato", "Veggie"}),
+			expectedKeys: []string{"postcode", "from", "to", "words"},
+		},
+		{
+			name:         "empty fields keep the config values",
+			profile:      "postcode-only",
+			expected:     cfg.WithPostcode("10121"),
+			expectedKeys: []string{"postcode"},
+		},
+		{
+			name:    "unknown profile",
+			profile: "nope",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		// avoid closure
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, keys, err := cfg.ApplyProfile(tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, but got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, but got %+v", tt.expected, got)
+			}
+			if !reflect.DeepEqual(keys, tt.expectedKeys) {
+				t.Errorf("Expected keys %v, but got %v", tt.expectedKeys, keys)
+			}
+		})
+	}
+}
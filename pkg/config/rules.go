@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Delivery hour formats of Rules.DeliveryHours
+const (
+	// DeliveryAMPM allows windows from an AM to a PM hour, e.g. Monday 9AM - 5PM
+	DeliveryAMPM = "am_pm"
+	// DeliveryAny allows any window whose start is before its end, e.g. Monday 2PM - 8PM
+	DeliveryAny = "any"
+)
+
+// Weekdays are the weekday names Rules.Weekdays accepts, ignoring case
+var Weekdays = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+
+// Rules configures the checks records must pass to be counted, see parser.NewRules.
+// A zero length or empty format keeps the default, so a zero Config still checks
+// the built-in limits.
+type Rules struct {
+	MaxPostcodeLength int `json:"max_postcode_length"`
+	MaxRecipeLength   int `json:"max_recipe_length"`
+	// PostcodePattern is a regular expression postcodes must match, e.g. ^\d{5}$
+	PostcodePattern string `json:"postcode_pattern"`
+	// Weekdays are the allowed delivery days, any word is allowed when empty
+	Weekdays      []string     `json:"weekdays"`
+	DeliveryHours string       `json:"delivery_hours"`
+	Custom        []CustomRule `json:"custom"`
+}
+
+// CustomRule rejects records whose Field does not match Pattern, or with Reject
+// set, the records whose Field matches it. ID is the rejection reason.
+type CustomRule struct {
+	ID      string `json:"id"`
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+	Reject  bool   `json:"reject"`
+}
+
+// DefaultRules are the limits the parser always had
+func DefaultRules() Rules {
+	return Rules{
+		MaxPostcodeLength: 10,
+		MaxRecipeLength:   100,
+		DeliveryHours:     DeliveryAMPM,
+	}
+}
+
+// String lists the rules that are set, custom rules by ID
+func (r Rules) String() string {
+	pairs := []string{
+		fmt.Sprintf("max_postcode_length=%d", r.MaxPostcodeLength),
+		fmt.Sprintf("max_recipe_length=%d", r.MaxRecipeLength),
+	}
+	if r.PostcodePattern != "" {
+		pairs = append(pairs, "postcode_pattern="+r.PostcodePattern)
+	}
+	if len(r.Weekdays) > 0 {
+		pairs = append(pairs, "weekdays="+strings.Join(r.Weekdays, "|"))
+	}
+	if r.DeliveryHours != "" {
+		pairs = append(pairs, "delivery_hours="+r.DeliveryHours)
+	}
+	for _, custom := range r.Custom {
+		pairs = append(pairs, "custom="+custom.ID)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// IDs of the built-in rules, they are the rejection reasons of the records failing
+// them in the logs and metrics
+const (
+	RuleEmptyPostcode   = "empty_postcode"
+	RuleLongPostcode    = "long_postcode"
+	RulePostcodePattern = "postcode_pattern"
+	RuleEmptyDelivery   = "empty_delivery"
+	RuleInvalidDelivery = "invalid_delivery"
+	RuleInvalidWeekday  = "invalid_weekday"
+	RuleEmptyRecipe     = "empty_recipe"
+	RuleLongRecipe      = "long_recipe"
+)
+
+// Rejection reasons of records dropped by the parser without a rule
+const (
+	RejectDecode    = "decode_error"
+	RejectDuplicate = "duplicate"
+)
+
+// BuiltinRuleIDs are the IDs of the rules every record is checked with and the
+// other rejection reasons, custom rules cannot reuse them
+var BuiltinRuleIDs = []string{
+	RuleEmptyPostcode,
+	RuleLongPostcode,
+	RulePostcodePattern,
+	RuleEmptyDelivery,
+	RuleInvalidDelivery,
+	RuleInvalidWeekday,
+	RuleEmptyRecipe,
+	RuleLongRecipe,
+	RejectDecode,
+	RejectDuplicate,
+}
+
+// ruleIDPattern keeps custom rule IDs usable as metric labels and log values
+var ruleIDPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// validateRules checks the limits compile and the custom rules are well-formed
+func (c Config) validateRules() ValidationErrors {
+	var errs ValidationErrors
+	r := c.Rules
+
+	if r.MaxPostcodeLength < 0 {
+		errs = append(errs, ValidationError{Key: "rules.max_postcode_length", Value: r.MaxPostcodeLength, Reason: "must not be negative"})
+	}
+	if r.MaxRecipeLength < 0 {
+		errs = append(errs, ValidationError{Key: "rules.max_recipe_length", Value: r.MaxRecipeLength, Reason: "must not be negative"})
+	}
+	if _, err := regexp.Compile(r.PostcodePattern); err != nil {
+		errs = append(errs, ValidationError{Key: "rules.postcode_pattern", Value: r.PostcodePattern, Reason: "is not a valid regular expression: " + err.Error()})
+	}
+	for _, day := range r.Weekdays {
+		if !slices.ContainsFunc(Weekdays, func(w string) bool { return strings.EqualFold(w, day) }) {
+			errs = append(errs, ValidationError{Key: "rules.weekdays", Value: day, Reason: "must be one of " + strings.Join(Weekdays, ", ")})
+		}
+	}
+	if r.DeliveryHours != "" && r.DeliveryHours != DeliveryAMPM && r.DeliveryHours != DeliveryAny {
+		errs = append(errs, ValidationError{Key: "rules.delivery_hours", Value: r.DeliveryHours, Reason: "must be am_pm or any"})
+	}
+
+	ids := make(map[string]bool, len(r.Custom))
+	for i, rule := range r.Custom {
+		key := fmt.Sprintf("rules.custom.%d", i)
+		switch {
+		case !ruleIDPattern.MatchString(rule.ID):
+			errs = append(errs, ValidationError{Key: key + ".id", Value: rule.ID, Reason: "must be lowercase letters, digits and underscores"})
+		case slices.Contains(BuiltinRuleIDs, rule.ID) || ids[rule.ID]:
+			errs = append(errs, ValidationError{Key: key + ".id", Value: rule.ID, Reason: "is already used by another rule"})
+		}
+		ids[rule.ID] = true
+		if !slices.Contains(fieldNames, rule.Field) {
+			errs = append(errs, ValidationError{Key: key + ".field", Value: rule.Field, Reason: "must be one of " + strings.Join(fieldNames, ", ")})
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			errs = append(errs, ValidationError{Key: key + ".pattern", Value: rule.Pattern, Reason: "is not a valid regular expression: " + err.Error()})
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,263 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const hourReason = "must be an hour from 1 to 12 followed by AM or PM, e.g. 10AM"
+
+// maxDedupeCapacity keeps the Bloom filter of the dedupe mode at about 2.4 GB with
+// the default false-positive rate
+const maxDedupeCapacity = 1_000_000_000
+
+// postcodePattern allows letters, digits, spaces and dashes, up to 10 characters
+var postcodePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 -]{0,9}$`)
+
+// ValidationError is a problem with one config value
+type ValidationError struct {
+	Key    string
+	Value  any
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s (%v): %s", e.Key, e.Value, e.Reason)
+}
+
+// ValidationErrors lists every problem found by Validate
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return fmt.Sprintf("invalid config:\n  %s", strings.Join(lines, "\n  "))
+}
+
+// Validate checks every value up front, so a bad value is reported before any
+// parsing starts instead of deep in the stats of the first matching record.
+// It returns ValidationErrors listing all problems, or nil.
+func (c Config) Validate() error {
+	var errs ValidationErrors
+	errs = append(errs, c.validateFile()...)
+	errs = append(errs, c.validateQuery("")...)
+	errs = append(errs, c.validateModes()...)
+	errs = append(errs, c.validateFields()...)
+	errs = append(errs, c.validateRules()...)
+	errs = append(errs, c.validateAliases()...)
+	errs = append(errs, c.validateProfiles()...)
+	errs = append(errs, c.validateLogging()...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateQuery checks the postcode, time window, words, field mapping, rules,
+// aliases, profiles and logging only, for commands that take their input files as
+// arguments
+func (c Config) ValidateQuery() error {
+	var errs ValidationErrors
+	errs = append(errs, c.validateQuery("")...)
+	errs = append(errs, c.validateFields()...)
+	errs = append(errs, c.validateRules()...)
+	errs = append(errs, c.validateAliases()...)
+	errs = append(errs, c.validateProfiles()...)
+	errs = append(errs, c.validateLogging()...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (c Config) validateFile() ValidationErrors {
+	if c.File == "" {
+		return ValidationErrors{{Key: "file", Value: c.File, Reason: "must not be empty"}}
+	}
+	file, err := os.Open(c.File)
+	if err != nil {
+		return ValidationErrors{{Key: "file", Value: c.File, Reason: "is not readable: " + errors.Cause(err).Error()}}
+	}
+	file.Close()
+	return nil
+}
+
+// validateQuery checks the values the postcode/time count and word matches depend on,
+// prefix qualifies the keys of a profile
+func (c Config) validateQuery(prefix string) ValidationErrors {
+	var errs ValidationErrors
+
+	if !postcodePattern.MatchString(c.Postcode) {
+		errs = append(errs, ValidationError{Key: prefix + "postcode", Value: c.Postcode, Reason: "must be 1 to 10 letters, digits, spaces or dashes"})
+	}
+
+	from, fromErr := ParseHour(c.FromTime)
+	if fromErr != nil {
+		errs = append(errs, ValidationError{Key: prefix + "from", Value: c.FromTime, Reason: hourReason})
+	}
+	to, toErr := ParseHour(c.ToTime)
+	if toErr != nil {
+		errs = append(errs, ValidationError{Key: prefix + "to", Value: c.ToTime, Reason: hourReason})
+	}
+	if fromErr == nil && toErr == nil && from >= to {
+		errs = append(errs, ValidationError{Key: prefix + "from", Value: c.FromTime, Reason: "must be before to (" + c.ToTime + ")"})
+	}
+
+	if len(c.Words) == 0 {
+		errs = append(errs, ValidationError{Key: prefix + "words", Value: c.Words, Reason: "must not be empty"})
+	}
+	for _, word := range c.Words {
+		if strings.TrimSpace(word) == "" {
+			errs = append(errs, ValidationError{Key: prefix + "words", Value: c.Words, Reason: "must not contain empty words"})
+			break
+		}
+	}
+
+	return errs
+}
+
+// validateModes checks the parser engine and the settings of the crosstab,
+// approximate, normalize, dedupe and state modes
+func (c Config) validateModes() ValidationErrors {
+	var errs ValidationErrors
+
+	if c.Engine != "json" && c.Engine != "fast" {
+		errs = append(errs, ValidationError{Key: "engine", Value: c.Engine, Reason: "must be json or fast"})
+	}
+
+	if c.Crosstab && len(c.CrosstabPostcodes) == 0 && c.CrosstabTopPostcodes < 1 {
+		errs = append(errs, ValidationError{Key: "crosstab_top_postcodes", Value: c.CrosstabTopPostcodes, Reason: "must be at least 1 when no crosstab postcodes are given"})
+	}
+	if c.CrosstabTopRecipes < 0 {
+		errs = append(errs, ValidationError{Key: "crosstab_top_recipes", Value: c.CrosstabTopRecipes, Reason: "must not be negative"})
+	}
+
+	if c.Approximate {
+		if c.ApproxPrecision < 4 || c.ApproxPrecision > 18 {
+			errs = append(errs, ValidationError{Key: "approx_precision", Value: c.ApproxPrecision, Reason: "must be between 4 and 18"})
+		}
+		if c.ApproxEpsilon <= 0 || c.ApproxEpsilon >= 1 {
+			errs = append(errs, ValidationError{Key: "approx_epsilon", Value: c.ApproxEpsilon, Reason: "must be between 0 and 1"})
+		}
+		if c.ApproxDelta <= 0 || c.ApproxDelta >= 1 {
+			errs = append(errs, ValidationError{Key: "approx_delta", Value: c.ApproxDelta, Reason: "must be between 0 and 1"})
+		}
+		if c.ApproxTop < 1 {
+			errs = append(errs, ValidationError{Key: "approx_top", Value: c.ApproxTop, Reason: "must be at least 1"})
+		}
+		if c.Crosstab {
+			errs = append(errs, ValidationError{Key: "crosstab", Value: c.Crosstab, Reason: "is not supported in approximate mode"})
+		}
+		if c.Extended {
+			errs = append(errs, ValidationError{Key: "extended", Value: c.Extended, Reason: "is not supported in approximate mode"})
+		}
+	}
+
+	if c.NormalizePostcodeWidth < 0 || c.NormalizePostcodeWidth > 10 {
+		errs = append(errs, ValidationError{Key: "normalize_postcode_width", Value: c.NormalizePostcodeWidth, Reason: "must be between 0 and 10"})
+	} else if c.NormalizePostcodeWidth > 0 && !c.Normalize {
+		errs = append(errs, ValidationError{Key: "normalize_postcode_width", Value: c.NormalizePostcodeWidth, Reason: "only applies with normalize"})
+	}
+
+	if c.Dedupe {
+		if c.DedupeCapacity < 1 || c.DedupeCapacity > maxDedupeCapacity {
+			errs = append(errs, ValidationError{Key: "dedupe_capacity", Value: c.DedupeCapacity, Reason: fmt.Sprintf("must be between 1 and %d", maxDedupeCapacity)})
+		}
+		if c.DedupeFalsePositive <= 0 || c.DedupeFalsePositive >= 1 {
+			errs = append(errs, ValidationError{Key: "dedupe_false_positive", Value: c.DedupeFalsePositive, Reason: "must be between 0 and 1"})
+		}
+	}
+
+	if c.State != "" && (c.Approximate || c.Crosstab) {
+		errs = append(errs, ValidationError{Key: "state", Value: c.State, Reason: "is not supported in approximate or crosstab mode"})
+	}
+	if len(c.Profile) > 1 && (c.State != "" || c.Approximate || c.Crosstab) {
+		errs = append(errs, ValidationError{Key: "profile", Value: c.Profile, Reason: "several profiles are not supported in state, approximate or crosstab mode"})
+	}
+
+	return errs
+}
+
+// logLevels are the zerolog level names
+var logLevels = []string{"trace", "debug", "info", "warn", "error", "fatal", "panic", "disabled"}
+
+// validateLogging checks the log level, format and rejection rate
+func (c Config) validateLogging() ValidationErrors {
+	var errs ValidationErrors
+	if !slices.Contains(logLevels, c.LogLevel) {
+		errs = append(errs, ValidationError{Key: "log_level", Value: c.LogLevel, Reason: "must be one of " + strings.Join(logLevels, ", ")})
+	}
+	if c.LogFormat != "console" && c.LogFormat != "json" {
+		errs = append(errs, ValidationError{Key: "log_format", Value: c.LogFormat, Reason: "must be console or json"})
+	}
+	if c.LogRejections < 0 {
+		errs = append(errs, ValidationError{Key: "log_rejections", Value: c.LogRejections, Reason: "must not be negative"})
+	}
+	return errs
+}
+
+// validateProfiles checks the selected profiles exist and every defined profile is valid
+func (c Config) validateProfiles() ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range c.Profile {
+		if _, ok := c.Profiles[name]; !ok {
+			errs = append(errs, ValidationError{Key: "profile", Value: name, Reason: "is not defined in profiles"})
+		}
+	}
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	// sorted so the errors are listed in a stable order
+	slices.Sort(names)
+	for _, name := range names {
+		profileCfg, _, _ := c.ApplyProfile(name)
+		errs = append(errs, profileCfg.validateQuery("profiles."+name+".")...)
+	}
+	return errs
+}
+
+// ParseHour parses an hour in the format 9AM or 3PM into the hour of the day.
+// 12AM is midnight (0) and 12PM is noon (12).
+func ParseHour(hourString string) (int, error) {
+	if !strings.HasSuffix(hourString, "AM") && !strings.HasSuffix(hourString, "PM") {
+		return 0, errors.New("hour does not end with AM or PM")
+	}
+
+	// remove AM/PM
+	hourTrimmed := strings.TrimSuffix(hourString, "AM")
+	hourTrimmed = strings.TrimSuffix(hourTrimmed, "PM")
+
+	// convert to int
+	hourInt, err := strconv.Atoi(hourTrimmed)
+	if err != nil {
+		return 0, err
+	}
+
+	// check the range between 1 and 12
+	if hourInt < 1 || hourInt > 12 {
+		return 0, errors.New("hour is not in range 1-12")
+	}
+
+	// 12AM is 0
+	if strings.Contains(hourString, "AM") && hourInt == 12 {
+		hourInt = 0
+	}
+
+	// if PM, add 12 hours, except for noon
+	if strings.Contains(hourString, "PM") && hourInt != 12 {
+		hourInt += 12
+	}
+
+	return hourInt, nil
+}
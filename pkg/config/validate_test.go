@@ -0,0 +1,145 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Default().WithFile("../stats/testdata/test.json")
+
+	tests := []struct {
+		name         string
+		cfg          Config
+		expectedKeys []string
+	}{
+		{
+			name: "valid config",
+			cfg:  valid,
+		},
+		{
+			name:         "unreadable file",
+			cfg:          valid.WithFile("./testdata/missing.json"),
+			expectedKeys: []string{"file"},
+		},
+		{
+			name:         "postcode too long and invalid hours",
+			cfg:          valid.WithPostcode("12345678901").WithFromTime("10").WithToTime("13PM"),
+			expectedKeys: []string{"postcode", "from", "to"},
+		},
+		{
+			name:         "from after to",
+			cfg:          valid.WithFromTime("3PM").WithToTime("10AM"),
+			expectedKeys: []string{"from"},
+		},
+		{
+			name:         "no words",
+			cfg:          valid.WithWords(nil),
+			expectedKeys: []string{"words"},
+		},
+		{
+			name:         "approximate settings",
+			cfg:          valid.WithApproximate(true).WithApproxPrecision(30).WithApproxEpsilon(0).WithApproxTop(0),
+			expectedKeys: []string{"approx_precision", "approx_epsilon", "approx_top"},
+		},
+		{
+			name:         "conflicting modes",
+			cfg:          valid.WithApproximate(true).WithCrosstab(true).WithExtended(true).WithState("state.json"),
+			expectedKeys: []string{"crosstab", "extended", "state"},
+		},
+		{
+			name:         "unknown engine",
+			cfg:          valid.WithEngine("simd"),
+			expectedKeys: []string{"engine"},
+		},
+		{
+			name:         "overlapping field paths",
+			cfg:          valid.WithFieldMapping(FieldMapping{Postcode: "/address", Delivery: "/address/slot"}),
+			expectedKeys: []string{"fields"},
+		},
+		{
+			name:         "dedupe settings",
+			cfg:          valid.WithDedupe(true).WithDedupeCapacity(0).WithDedupeFalsePositive(1),
+			expectedKeys: []string{"dedupe_capacity", "dedupe_false_positive"},
+		},
+		{
+			name:         "unreadable alias file",
+			cfg:          valid.WithAliases("./testdata/missing.yaml"),
+			expectedKeys: []string{"aliases"},
+		},
+		{
+			name:         "show aliases without alias file",
+			cfg:          valid.WithShowAliases(true),
+			expectedKeys: []string{"show_aliases"},
+		},
+		{
+			name:         "postcode width without normalize",
+			cfg:          valid.WithNormalizePostcodeWidth(5),
+			expectedKeys: []string{"normalize_postcode_width"},
+		},
+		{
+			name:         "negative postcode width",
+			cfg:          valid.WithNormalize(true).WithNormalizePostcodeWidth(-1),
+			expectedKeys: []string{"normalize_postcode_width"},
+		},
+		{
+			name: "rules",
+			cfg: valid.WithRules(Rules{
+				MaxPostcodeLength: -1,
+				PostcodePattern:   "(",
+				Weekdays:          []string{"monday", "Someday"},
+				DeliveryHours:     "24h",
+				Custom: []CustomRule{
+					{ID: "no_test", Field: "recipe", Pattern: "(?i)test", Reject: true},
+					{ID: "no_test", Field: "zip", Pattern: "x"},
+					{ID: RuleLongRecipe, Field: "recipe", Pattern: "["},
+				},
+			}),
+			expectedKeys: []string{
+				"rules.max_postcode_length", "rules.postcode_pattern", "rules.weekdays", "rules.delivery_hours",
+				"rules.custom.1.id", "rules.custom.1.field", "rules.custom.2.id", "rules.custom.2.pattern",
+			},
+		},
+		{
+			name:         "logging",
+			cfg:          valid.WithLogLevel("loud").WithLogFormat("xml").WithLogRejections(-1),
+			expectedKeys: []string{"log_level", "log_format", "log_rejections"},
+		},
+		{
+			name: "profiles",
+			cfg: func() Config {
+				cfg := valid.WithProfile([]string{"lunch", "missing"})
+				cfg.Profiles = map[string]Profile{"lunch": {FromTime: "4PM"}}
+				return cfg
+			}(),
+			expectedKeys: []string{"profile", "profiles.lunch.from"},
+		},
+	}
+
+	for _, tt := range tests {
+		// avoid closure
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if len(tt.expectedKeys) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, but got %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Expected ValidationErrors, but got %v", err)
+			}
+			var keys []string
+			for _, e := range errs {
+				keys = append(keys, e.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.expectedKeys) {
+				t.Errorf("Expected errors for %v, but got %v", tt.expectedKeys, errs)
+			}
+		})
+	}
+}
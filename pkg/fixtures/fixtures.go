@@ -0,0 +1,409 @@
+// Package fixtures generates deterministic recipe deliveries for tests and benchmarks,
+// including malformed records rejected by the parser.
+package fixtures
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
+)
+
+// Output formats of Write
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// Postcode distributions
+const (
+	Uniform = "uniform"
+	Zipf    = "zipf"
+)
+
+// maxPostcodes is the number of five digit postcodes from 10000
+const maxPostcodes = 90000
+
+// Rules lists the parser rejections a record can be generated for, in the order
+// they are drawn
+var Rules = []string{
+	metrics.ReasonEmptyPostcode,
+	metrics.ReasonLongPostcode,
+	metrics.ReasonEmptyDelivery,
+	metrics.ReasonInvalidDelivery,
+	metrics.ReasonEmptyRecipe,
+	metrics.ReasonLongRecipe,
+}
+
+// DefaultRecipes is the recipe vocabulary of the test fixtures
+var DefaultRecipes = []string{
+	"Cajun-Spiced Pulled Pork", "Cheesy Chicken Enchilada Bake", "Cherry Balsamic Pork Chops",
+	"Chicken Pineapple Quesadillas", "Chicken Sausage Pizzas", "Creamy Dill Chicken",
+	"Creamy Shrimp Tagliatelle", "Garden Quesadillas", "Garlic Herb Butter Steak",
+	"Grilled Cheese and Veggie Jumble", "Hearty Pork Chili", "Honey Sesame Chicken",
+	"Hot Honey Barbecue Chicken Legs", "Korean-Style Chicken Thighs", "Meatloaf à La Mom",
+	"Mediterranean Baked Mushroom", "Mediterranean Baked Veggies", "Melty Monterey Jack Burgers",
+	"Mole-Spiced Beef Tacos", "One-Pan Orzo Italiano", "Parmesan-Crusted Pork Tenderloin",
+	"Spanish One-Pan Chicken", "Speedy Steak Fajitas", "Spinach Artichoke Pasta Bake",
+	"Steakhouse-Style New York Strip", "Stovetop Mac 'N' Cheese", "Sweet Apple Pork Tenderloin",
+	"Tex-Mex Tilapia", "Yellow Squash Flatbreads",
+}
+
+// record is one generated delivery, in the field order of the fixtures
+type record struct {
+	Postcode string `json:"postcode"`
+	Recipe   string `json:"recipe"`
+	Delivery string `json:"delivery"`
+}
+
+// Summary counts the records written, Malformed by rule
+type Summary struct {
+	Records   int            `json:"records"`
+	Malformed map[string]int `json:"malformed"`
+}
+
+// Generator writes the same records for the same seed and options
+type Generator struct {
+	records      int
+	seed         int64
+	recipes      []string
+	postcodes    int
+	distribution string
+	zipfS        float64
+	weekdays     map[string]float64
+	malformed    map[string]float64
+}
+
+// NewGenerator generates records deliveries of the default recipes to 1000
+// uniformly distributed postcodes on every weekday, without malformed records
+func NewGenerator(records int, seed int64) *Generator {
+	return &Generator{
+		records:      records,
+		seed:         seed,
+		recipes:      DefaultRecipes,
+		postcodes:    1000,
+		distribution: Uniform,
+		zipfS:        1.1,
+	}
+}
+
+// WithRecipes sets the recipe vocabulary, recipes are drawn uniformly
+func (g *Generator) WithRecipes(recipes []string) *Generator {
+	g.recipes = recipes
+	return g
+}
+
+// WithPostcodes draws from n postcodes, uniformly or following a Zipf distribution
+// with exponent s > 1 where the first postcode is the busiest
+func (g *Generator) WithPostcodes(n int, distribution string, s float64) *Generator {
+	g.postcodes = n
+	g.distribution = distribution
+	g.zipfS = s
+	return g
+}
+
+// WithWeekdays weights the delivery weekdays, days left out are not generated.
+// Without weights every weekday is equally likely.
+func (g *Generator) WithWeekdays(weights map[string]float64) *Generator {
+	g.weekdays = weights
+	return g
+}
+
+// WithMalformed sets the share of records breaking each rule of Rules
+func (g *Generator) WithMalformed(rates map[string]float64) *Generator {
+	g.malformed = rates
+	return g
+}
+
+// Validate returns config.ValidationErrors listing every invalid option, or nil
+func (g *Generator) Validate() error {
+	var errs config.ValidationErrors
+	if g.records < 0 {
+		errs = append(errs, config.ValidationError{Key: "records", Value: g.records, Reason: "must not be negative"})
+	}
+	if len(g.recipes) == 0 {
+		errs = append(errs, config.ValidationError{Key: "recipes", Value: g.recipes, Reason: "must not be empty"})
+	}
+	for _, recipe := range g.recipes {
+		if recipe == "" || len(recipe) > 100 {
+			errs = append(errs, config.ValidationError{Key: "recipes", Value: recipe, Reason: "must be 1 to 100 characters"})
+		}
+	}
+	if g.postcodes < 1 || g.postcodes > maxPostcodes {
+		errs = append(errs, config.ValidationError{Key: "postcodes", Value: g.postcodes, Reason: fmt.Sprintf("must be between 1 and %d", maxPostcodes)})
+	}
+	if g.distribution != Uniform && g.distribution != Zipf {
+		errs = append(errs, config.ValidationError{Key: "distribution", Value: g.distribution, Reason: "must be uniform or zipf"})
+	}
+	if g.distribution == Zipf && g.zipfS <= 1 {
+		errs = append(errs, config.ValidationError{Key: "zipf-s", Value: g.zipfS, Reason: "must be greater than 1"})
+	}
+
+	var total float64
+	for day, weight := range g.weekdays {
+		if _, ok := weekday(day); !ok {
+			errs = append(errs, config.ValidationError{Key: "weekdays", Value: day, Reason: "must be a weekday, e.g. Monday"})
+		}
+		if weight < 0 {
+			errs = append(errs, config.ValidationError{Key: "weekdays", Value: weight, Reason: fmt.Sprintf("weight of %s must not be negative", day)})
+		}
+		total += weight
+	}
+	if len(g.weekdays) > 0 && total <= 0 {
+		errs = append(errs, config.ValidationError{Key: "weekdays", Value: g.weekdays, Reason: "a weight must be positive"})
+	}
+
+	total = 0
+	for rule, rate := range g.malformed {
+		if !isRule(rule) {
+			errs = append(errs, config.ValidationError{Key: "malformed", Value: rule, Reason: "must be one of " + strings.Join(Rules, ", ")})
+		}
+		if rate < 0 || rate > 1 {
+			errs = append(errs, config.ValidationError{Key: "malformed", Value: rate, Reason: fmt.Sprintf("rate of %s must be between 0 and 1", rule)})
+		}
+		total += rate
+	}
+	if total > 1 {
+		errs = append(errs, config.ValidationError{Key: "malformed", Value: total, Reason: "rates must add up to at most 1"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func isRule(rule string) bool {
+	for _, r := range Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// week lists the weekdays from Monday
+var week = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+// weekday returns the canonical name of day, ignoring case
+func weekday(day string) (string, bool) {
+	for _, d := range week {
+		if strings.EqualFold(day, d.String()) {
+			return d.String(), true
+		}
+	}
+	return "", false
+}
+
+// Write generates the records in format, a JSON array, NDJSON or CSV with a header
+func (g *Generator) Write(w io.Writer, format string) (Summary, error) {
+	if err := g.Validate(); err != nil {
+		return Summary{}, err
+	}
+	var out writer
+	buf := bufio.NewWriter(w)
+	switch format {
+	case FormatJSON:
+		out = &jsonWriter{w: buf, array: true}
+	case FormatNDJSON:
+		out = &jsonWriter{w: buf}
+	case FormatCSV:
+		out = &csvWriter{w: csv.NewWriter(buf)}
+	default:
+		return Summary{}, errors.Errorf("invalid format %q, must be json, ndjson or csv", format)
+	}
+
+	summary := Summary{Malformed: make(map[string]int)}
+	d := g.newDraw()
+	for i := 0; i < g.records; i++ {
+		rec, rule := d.record()
+		if rule != "" {
+			summary.Malformed[rule]++
+		}
+		if err := out.write(rec); err != nil {
+			return summary, errors.Wrap(err, "failed to write record")
+		}
+		summary.Records++
+	}
+	if err := out.close(); err != nil {
+		return summary, errors.Wrap(err, "failed to write records")
+	}
+	return summary, errors.Wrap(buf.Flush(), "failed to write records")
+}
+
+// draw holds the random sources of one Write
+type draw struct {
+	g       *Generator
+	rand    *rand.Rand
+	zipf    *rand.Zipf
+	days    []string
+	weights []float64
+	total   float64
+	rates   []float64
+}
+
+func (g *Generator) newDraw() *draw {
+	d := &draw{g: g, rand: rand.New(rand.NewSource(g.seed))}
+	if g.distribution == Zipf {
+		d.zipf = rand.NewZipf(d.rand, g.zipfS, 1, uint64(g.postcodes-1))
+	}
+	weights := make(map[string]float64, len(g.weekdays))
+	for day, weight := range g.weekdays {
+		day, _ = weekday(day)
+		weights[day] += weight
+	}
+	// weekdays in calendar order, so the map order does not change the output
+	for _, day := range week {
+		weight, ok := weights[day.String()]
+		if len(weights) == 0 {
+			weight, ok = 1, true
+		}
+		if ok && weight > 0 {
+			d.days = append(d.days, day.String())
+			d.weights = append(d.weights, weight)
+			d.total += weight
+		}
+	}
+	for _, rule := range Rules {
+		d.rates = append(d.rates, g.malformed[rule])
+	}
+	return d
+}
+
+// record returns a valid record, or one breaking the returned rule
+func (d *draw) record() (record, string) {
+	rec := record{
+		Postcode: d.postcode(),
+		Recipe:   d.g.recipes[d.rand.Intn(len(d.g.recipes))],
+		Delivery: fmt.Sprintf("%s %dAM - %dPM", d.weekday(), d.rand.Intn(12)+1, d.rand.Intn(12)+1),
+	}
+
+	// one draw per record, so a rate of 0 leaves the other records unchanged
+	u := d.rand.Float64()
+	for i, rule := range Rules {
+		if u >= d.rates[i] {
+			u -= d.rates[i]
+			continue
+		}
+		switch rule {
+		case metrics.ReasonEmptyPostcode:
+			rec.Postcode = ""
+		case metrics.ReasonLongPostcode:
+			rec.Postcode += "000000"
+		case metrics.ReasonEmptyDelivery:
+			rec.Delivery = ""
+		case metrics.ReasonInvalidDelivery:
+			// PM before AM does not match the delivery format
+			rec.Delivery = fmt.Sprintf("%s %dPM - %dAM", d.weekday(), d.rand.Intn(12)+1, d.rand.Intn(12)+1)
+		case metrics.ReasonEmptyRecipe:
+			rec.Recipe = ""
+		case metrics.ReasonLongRecipe:
+			rec.Recipe += " " + strings.Repeat("x", 100)
+		}
+		return rec, rule
+	}
+	return rec, ""
+}
+
+func (d *draw) postcode() string {
+	var rank int
+	if d.zipf != nil {
+		rank = int(d.zipf.Uint64())
+	} else {
+		rank = d.rand.Intn(d.g.postcodes)
+	}
+	return fmt.Sprintf("%05d", 10000+rank)
+}
+
+func (d *draw) weekday() string {
+	u := d.rand.Float64() * d.total
+	for i, weight := range d.weights {
+		if u < weight {
+			return d.days[i]
+		}
+		u -= weight
+	}
+	return d.days[len(d.days)-1]
+}
+
+// writer writes the records of one format
+type writer interface {
+	write(rec record) error
+	close() error
+}
+
+// jsonWriter writes a JSON array with one record per line, or NDJSON
+type jsonWriter struct {
+	w       *bufio.Writer
+	array   bool
+	written bool
+}
+
+func (j *jsonWriter) write(rec record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !j.array:
+	case j.written:
+		j.w.WriteString(",\n  ")
+	default:
+		j.w.WriteString("[\n  ")
+	}
+	j.written = true
+	j.w.Write(b)
+	if !j.array {
+		j.w.WriteByte('\n')
+	}
+	return nil
+}
+
+func (j *jsonWriter) close() error {
+	if !j.array {
+		return nil
+	}
+	if !j.written {
+		_, err := j.w.WriteString("[]\n")
+		return err
+	}
+	_, err := j.w.WriteString("\n]\n")
+	return err
+}
+
+// csvWriter writes a header and one row per record
+type csvWriter struct {
+	w       *csv.Writer
+	written bool
+}
+
+func (c *csvWriter) write(rec record) error {
+	if !c.written {
+		c.written = true
+		if err := c.w.Write([]string{"postcode", "recipe", "delivery"}); err != nil {
+			return err
+		}
+	}
+	return c.w.Write([]string{rec.Postcode, rec.Recipe, rec.Delivery})
+}
+
+func (c *csvWriter) close() error {
+	if !c.written {
+		if err := c.w.Write([]string{"postcode", "recipe", "delivery"}); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,217 @@
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+func generate(t *testing.T, g *Generator, format string) (string, Summary) {
+	var buf bytes.Buffer
+	summary, err := g.Write(&buf, format)
+	if err != nil {
+		t.Fatalf("Error generating fixtures: %v", err)
+	}
+	return buf.String(), summary
+}
+
+func decode(t *testing.T, content string) []record {
+	var records []record
+	if err := json.Unmarshal([]byte(content), &records); err != nil {
+		t.Fatalf("Error decoding fixtures: %v", err)
+	}
+	return records
+}
+
+func TestGenerator_Deterministic(t *testing.T) {
+	first, _ := generate(t, NewGenerator(100, 42), FormatJSON)
+	second, _ := generate(t, NewGenerator(100, 42), FormatJSON)
+	other, _ := generate(t, NewGenerator(100, 43), FormatJSON)
+
+	if first != second {
+		t.Error("Expected the same fixtures for the same seed, but they differ")
+	}
+	if first == other {
+		t.Error("Expected different fixtures for another seed, but they are the same")
+	}
+}
+
+func TestGenerator_Formats(t *testing.T) {
+	g := func() *Generator { return NewGenerator(10, 1) }
+	content, _ := generate(t, g(), FormatJSON)
+	records := decode(t, content)
+	if len(records) != 10 {
+		t.Fatalf("Expected %v records, but got %v", 10, len(records))
+	}
+
+	content, _ = generate(t, g(), FormatNDJSON)
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	if len(lines) != len(records) {
+		t.Fatalf("Expected %v lines, but got %v", len(records), len(lines))
+	}
+	for i, line := range lines {
+		var rec record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("Error decoding line %q: %v", line, err)
+		}
+		if rec != records[i] {
+			t.Errorf("Expected %v, but got %v", records[i], rec)
+		}
+	}
+
+	content, _ = generate(t, g(), FormatCSV)
+	rows, err := csv.NewReader(strings.NewReader(content)).ReadAll()
+	if err != nil {
+		t.Fatalf("Error reading CSV: %v", err)
+	}
+	if !reflect.DeepEqual(rows[0], []string{"postcode", "recipe", "delivery"}) || len(rows) != len(records)+1 {
+		t.Fatalf("Expected a header and %v rows, but got %v", len(records), rows)
+	}
+	for i, row := range rows[1:] {
+		if rec := (record{Postcode: row[0], Recipe: row[1], Delivery: row[2]}); rec != records[i] {
+			t.Errorf("Expected %v, but got %v", records[i], rec)
+		}
+	}
+
+	if content, _ := generate(t, NewGenerator(0, 1), FormatJSON); len(decode(t, content)) != 0 {
+		t.Errorf("Expected an empty array, but got %q", content)
+	}
+}
+
+func TestGenerator_Malformed(t *testing.T) {
+	rates := make(map[string]float64, len(Rules))
+	for _, rule := range Rules {
+		rates[rule] = 0.05
+	}
+	content, summary := generate(t, NewGenerator(2000, 7).WithMalformed(rates), FormatJSON)
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Error writing fixtures: %v", err)
+	}
+
+	malformed := 0
+	for _, rule := range Rules {
+		if summary.Malformed[rule] == 0 {
+			t.Errorf("Expected %v records, but got none", rule)
+		}
+		malformed += summary.Malformed[rule]
+	}
+
+	// every malformed record is rejected by the parser for its rule
+	for _, engine := range []string{"json", "fast"} {
+		registry := metrics.NewRegistry()
+		p := parser.NewJsonParser(config.Config{File: path, Engine: engine}).WithMetrics(registry)
+		go p.Parse(context.Background())
+		for range p.Stream() {
+		}
+
+		got := registry.Snapshot()
+		if !reflect.DeepEqual(got.RecordsRejected, toInt64(summary.Malformed)) {
+			t.Errorf("Expected %v, but got %v", summary.Malformed, got.RecordsRejected)
+		}
+		if got.RecordsParsed != int64(summary.Records-malformed) {
+			t.Errorf("Expected %v, but got %v", summary.Records-malformed, got.RecordsParsed)
+		}
+	}
+}
+
+func toInt64(counts map[string]int) map[string]int64 {
+	converted := make(map[string]int64, len(counts))
+	for key, count := range counts {
+		converted[key] = int64(count)
+	}
+	return converted
+}
+
+func TestGenerator_Distributions(t *testing.T) {
+	busiest := func(g *Generator) int {
+		content, _ := generate(t, g, FormatJSON)
+		counts := make(map[string]int)
+		top := 0
+		for _, rec := range decode(t, content) {
+			counts[rec.Postcode]++
+			top = max(top, counts[rec.Postcode])
+		}
+		return top
+	}
+	uniform := busiest(NewGenerator(5000, 1).WithPostcodes(100, Uniform, 0))
+	zipf := busiest(NewGenerator(5000, 1).WithPostcodes(100, Zipf, 1.5))
+	if zipf < 5*uniform {
+		t.Errorf("Expected the busiest Zipf postcode to have over %v records, but got %v", 5*uniform, zipf)
+	}
+
+	content, _ := generate(t, NewGenerator(200, 1).WithWeekdays(map[string]float64{"monday": 1, "Friday": 0}), FormatJSON)
+	for _, rec := range decode(t, content) {
+		if !strings.HasPrefix(rec.Delivery, "Monday ") {
+			t.Fatalf("Expected only Monday deliveries, but got %v", rec.Delivery)
+		}
+	}
+}
+
+func TestGenerator_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator *Generator
+		keys      []string
+	}{
+		{
+			name:      "defaults",
+			generator: NewGenerator(10, 1),
+		},
+		{
+			name:      "negative records",
+			generator: NewGenerator(-1, 1),
+			keys:      []string{"records"},
+		},
+		{
+			name:      "long recipe",
+			generator: NewGenerator(10, 1).WithRecipes([]string{strings.Repeat("x", 101)}),
+			keys:      []string{"recipes"},
+		},
+		{
+			name:      "postcodes",
+			generator: NewGenerator(10, 1).WithPostcodes(0, "normal", 1),
+			keys:      []string{"postcodes", "distribution"},
+		},
+		{
+			name:      "zipf exponent",
+			generator: NewGenerator(10, 1).WithPostcodes(10, Zipf, 1),
+			keys:      []string{"zipf-s"},
+		},
+		{
+			name:      "unknown weekday",
+			generator: NewGenerator(10, 1).WithWeekdays(map[string]float64{"Someday": 1}),
+			keys:      []string{"weekdays"},
+		},
+		{
+			name:      "malformed rates",
+			generator: NewGenerator(10, 1).WithMalformed(map[string]float64{"empty_postcode": 0.6, "empty_recipe": 0.6}),
+			keys:      []string{"malformed"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			if errs, ok := tt.generator.Validate().(config.ValidationErrors); ok {
+				for _, err := range errs {
+					keys = append(keys, err.Key)
+				}
+			}
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("Expected %v, but got %v", tt.keys, keys)
+			}
+		})
+	}
+}
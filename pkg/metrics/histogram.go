@@ -0,0 +1,48 @@
+package metrics
+
+// Histogram counts observations in cumulative buckets like a Prometheus histogram.
+// It is not safe for concurrent use, Registry guards it.
+type Histogram struct {
+	buckets []float64
+	counts  []int64
+	sum     float64
+	count   int64
+}
+
+// NewHistogram creates a histogram with the given ascending upper bounds
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]int64, len(buckets)),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Bucket is the number of observations less than or equal to UpperBound
+type Bucket struct {
+	UpperBound float64 `json:"le"`
+	Count      int64   `json:"count"`
+}
+
+type HistogramSnapshot struct {
+	Buckets []Bucket `json:"buckets"`
+	Sum     float64  `json:"sum"`
+	Count   int64    `json:"count"`
+}
+
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	buckets := make([]Bucket, len(h.buckets))
+	for i, bound := range h.buckets {
+		buckets[i] = Bucket{UpperBound: bound, Count: h.counts[i]}
+	}
+	return HistogramSnapshot{Buckets: buckets, Sum: h.sum, Count: h.count}
+}
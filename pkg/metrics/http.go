@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"strconv"
+)
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	s := r.Snapshot()
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "# HELP jsonreader_records_parsed_total Records streamed by the parser.")
+	fmt.Fprintln(bw, "# TYPE jsonreader_records_parsed_total counter")
+	fmt.Fprintf(bw, "jsonreader_records_parsed_total %d\n", s.RecordsParsed)
+
+	fmt.Fprintln(bw, "# HELP jsonreader_records_rejected_total Records dropped by the parser, by reason.")
+	fmt.Fprintln(bw, "# TYPE jsonreader_records_rejected_total counter")
+	reasons := make([]string, 0, len(s.RecordsRejected))
+	for reason := range s.RecordsRejected {
+		reasons = append(reasons, reason)
+	}
+	slices.Sort(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(bw, "jsonreader_records_rejected_total{reason=%q} %d\n", reason, s.RecordsRejected[reason])
+	}
+
+	writeHistogram(bw, "jsonreader_parse_duration_seconds", "Duration of a parser run.", s.ParseDuration)
+	writeHistogram(bw, "jsonreader_query_duration_seconds", "Duration of a stats query.", s.QueryDuration)
+
+	return bw.Flush()
+}
+
+func writeHistogram(w io.Writer, name, help string, h HistogramSnapshot) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, b := range h.Buckets {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, strconv.FormatFloat(b.UpperBound, 'g', -1, 64), b.Count)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.Count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.Sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.Count)
+}
+
+// Handler serves /metrics, and the net/http/pprof endpoints under /debug/pprof/
+// when withPprof is set
+func (r *Registry) Handler(withPprof bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WritePrometheus(w)
+	})
+	if withPprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
@@ -0,0 +1,114 @@
+// Package metrics counts parsed and rejected records and times parsing and queries.
+// A Registry exposes them in the Prometheus text format, or as JSON for one-shot runs.
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+)
+
+// Rejection reasons of records that are not streamed by the parser, the IDs of the
+// rules they fail, see config.BuiltinRuleIDs
+const (
+	ReasonDecode          = config.RejectDecode
+	ReasonEmptyPostcode   = config.RuleEmptyPostcode
+	ReasonLongPostcode    = config.RuleLongPostcode
+	ReasonPostcodePattern = config.RulePostcodePattern
+	ReasonEmptyDelivery   = config.RuleEmptyDelivery
+	ReasonInvalidDelivery = config.RuleInvalidDelivery
+	ReasonInvalidWeekday  = config.RuleInvalidWeekday
+	ReasonEmptyRecipe     = config.RuleEmptyRecipe
+	ReasonLongRecipe      = config.RuleLongRecipe
+	ReasonDuplicate       = config.RejectDuplicate
+)
+
+// Metrics is implemented by the parser and stats instrumentation, see Registry and Nop
+type Metrics interface {
+	// RecordParsed counts a record streamed by the parser
+	RecordParsed()
+	// RecordRejected counts a record dropped by the parser for reason
+	RecordRejected(reason string)
+	// ObserveParse times a whole Parse run
+	ObserveParse(d time.Duration)
+	// ObserveQuery times a stats Generate call
+	ObserveQuery(d time.Duration)
+}
+
+// Nop discards every metric, it is the default of the parser and stats
+type Nop struct{}
+
+func (Nop) RecordParsed()                {}
+func (Nop) RecordRejected(reason string) {}
+func (Nop) ObserveParse(d time.Duration) {}
+func (Nop) ObserveQuery(d time.Duration) {}
+
+// DefaultBuckets are the upper bounds, in seconds, of the duration histograms
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+// Registry keeps the metrics in memory, it is safe for concurrent use
+type Registry struct {
+	parsed atomic.Int64
+
+	mu       sync.Mutex
+	rejected map[string]int64
+	parse    *Histogram
+	query    *Histogram
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		rejected: make(map[string]int64),
+		parse:    NewHistogram(DefaultBuckets),
+		query:    NewHistogram(DefaultBuckets),
+	}
+}
+
+func (r *Registry) RecordParsed() {
+	r.parsed.Add(1)
+}
+
+func (r *Registry) RecordRejected(reason string) {
+	r.mu.Lock()
+	r.rejected[reason]++
+	r.mu.Unlock()
+}
+
+func (r *Registry) ObserveParse(d time.Duration) {
+	r.mu.Lock()
+	r.parse.Observe(d.Seconds())
+	r.mu.Unlock()
+}
+
+func (r *Registry) ObserveQuery(d time.Duration) {
+	r.mu.Lock()
+	r.query.Observe(d.Seconds())
+	r.mu.Unlock()
+}
+
+// Snapshot is a copy of the metrics, it is printed as JSON by the CLI
+type Snapshot struct {
+	RecordsParsed   int64             `json:"records_parsed"`
+	RecordsRejected map[string]int64  `json:"records_rejected"`
+	ParseDuration   HistogramSnapshot `json:"parse_duration_seconds"`
+	QueryDuration   HistogramSnapshot `json:"query_duration_seconds"`
+}
+
+// Snapshot copies the current metrics
+func (r *Registry) Snapshot() Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rejected := make(map[string]int64, len(r.rejected))
+	for reason, count := range r.rejected {
+		rejected[reason] = count
+	}
+	return Snapshot{
+		RecordsParsed:   r.parsed.Load(),
+		RecordsRejected: rejected,
+		ParseDuration:   r.parse.Snapshot(),
+		QueryDuration:   r.query.Snapshot(),
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Snapshot(t *testing.T) {
+	r := NewRegistry()
+	r.RecordParsed()
+	r.RecordParsed()
+	r.RecordRejected(ReasonEmptyPostcode)
+	r.RecordRejected(ReasonDecode)
+	r.RecordRejected(ReasonDecode)
+	r.ObserveParse(20 * time.Millisecond)
+	r.ObserveQuery(2 * time.Second)
+
+	got := r.Snapshot()
+	if got.RecordsParsed != 2 {
+		t.Errorf("Expected %v, but got %v", 2, got.RecordsParsed)
+	}
+	expectedRejected := map[string]int64{ReasonEmptyPostcode: 1, ReasonDecode: 2}
+	if !reflect.DeepEqual(got.RecordsRejected, expectedRejected) {
+		t.Errorf("Expected %v, but got %v", expectedRejected, got.RecordsRejected)
+	}
+	if got.ParseDuration.Count != 1 || got.QueryDuration.Count != 1 {
+		t.Errorf("Expected one observation each, but got %+v and %+v", got.ParseDuration, got.QueryDuration)
+	}
+}
+
+func TestHistogram_Observe(t *testing.T) {
+	h := NewHistogram([]float64{0.1, 1, 10})
+	for _, v := range []float64{0.05, 0.5, 0.5, 5, 50} {
+		h.Observe(v)
+	}
+
+	expected := HistogramSnapshot{
+		Buckets: []Bucket{{UpperBound: 0.1, Count: 1}, {UpperBound: 1, Count: 3}, {UpperBound: 10, Count: 4}},
+		Sum:     56.05,
+		Count:   5,
+	}
+	if got := h.Snapshot(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, got)
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.RecordParsed()
+	r.RecordRejected(ReasonLongRecipe)
+	r.ObserveQuery(3 * time.Millisecond)
+
+	tests := []struct {
+		name      string
+		withPprof bool
+		path      string
+		status    int
+		contains  []string
+	}{
+		{
+			name:   "metrics",
+			path:   "/metrics",
+			status: http.StatusOK,
+			contains: []string{
+				"# TYPE jsonreader_records_parsed_total counter",
+				"jsonreader_records_parsed_total 1\n",
+				`jsonreader_records_rejected_total{reason="long_recipe"} 1`,
+				`jsonreader_query_duration_seconds_bucket{le="0.005"} 1`,
+				`jsonreader_query_duration_seconds_bucket{le="+Inf"} 1`,
+				"jsonreader_parse_duration_seconds_count 0\n",
+			},
+		},
+		{name: "pprof disabled", path: "/debug/pprof/", status: http.StatusNotFound},
+		{name: "pprof enabled", withPprof: true, path: "/debug/pprof/", status: http.StatusOK, contains: []string{"goroutine"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.Handler(tt.withPprof).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("Expected %v, but got %v", tt.status, rec.Code)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(rec.Body.String(), s) {
+					t.Errorf("Expected %q in\n%s", s, rec.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package parser
+
+import "sync"
+
+// BatchSize is the number of entries per Batch
+const BatchSize = 256
+
+// Batch is a slice of streamed entries. Handing over batches instead of single
+// entries costs one channel operation per BatchSize records.
+type Batch struct {
+	Entries []Entry
+}
+
+var batchPool = sync.Pool{
+	New: func() any {
+		return &Batch{Entries: make([]Entry, 0, BatchSize)}
+	},
+}
+
+func newBatch() *Batch {
+	return batchPool.Get().(*Batch)
+}
+
+// Release returns the batch to the pool once its entries are processed, it must
+// not be used afterwards
+func (b *Batch) Release() {
+	// drop the references to the recipe strings
+	clear(b.Entries)
+	b.Entries = b.Entries[:0]
+	batchPool.Put(b)
+}
+
+// unbatch feeds the single-entry stream from the batches, see JsonParser.Stream.
+// It stops once the context of Parse is cancelled, even if nobody reads the stream.
+func (r *JsonParser) unbatch() {
+	defer close(r.stream)
+	for batch := range r.batches {
+		for _, entry := range batch.Entries {
+			select {
+			case r.stream <- entry:
+			case <-r.done:
+				batch.Release()
+				return
+			}
+		}
+		batch.Release()
+	}
+}
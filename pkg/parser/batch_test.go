@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+)
+
+func TestJsonParser_Batches(t *testing.T) {
+	path := benchmarkFixtures(t, 1)
+	for _, engine := range []string{"json", "fast"} {
+		engine := engine // avoid closure
+		t.Run(engine, func(t *testing.T) {
+			cfg := config.Config{File: path, Engine: engine}
+
+			expected := collectEntries(NewJsonParser(cfg))
+
+			p := NewJsonParser(cfg)
+			go p.Parse(context.Background())
+			var actual []parsedEntry
+			for batch := range p.Batches() {
+				if len(batch.Entries) > BatchSize {
+					t.Errorf("Expected at most %v entries, but got %v", BatchSize, len(batch.Entries))
+				}
+				for _, entry := range batch.Entries {
+					actual = append(actual, toParsedEntry(entry))
+				}
+				batch.Release()
+			}
+
+			if len(actual) != len(expected) {
+				t.Fatalf("Expected %v entries, but got %v", len(expected), len(actual))
+			}
+			for i := range expected {
+				if actual[i] != expected[i] {
+					t.Errorf("Expected %v, but got %v", expected[i], actual[i])
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkJsonParser_Consume compares reading the parser entry by entry with
+// reading it in batches
+func BenchmarkJsonParser_Consume(b *testing.B) {
+	path := benchmarkFixtures(b, 1000)
+	info, err := os.Stat(path)
+	if err != nil {
+		b.Fatalf("Error reading fixtures: %v", err)
+	}
+	cfg := config.Config{File: path, Engine: "fast"}
+
+	b.Run("stream", func(b *testing.B) {
+		b.SetBytes(info.Size())
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			p := NewJsonParser(cfg)
+			go p.Parse(context.Background())
+			for range p.Stream() {
+			}
+		}
+	})
+	b.Run("batches", func(b *testing.B) {
+		b.SetBytes(info.Size())
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			p := NewJsonParser(cfg)
+			go p.Parse(context.Background())
+			for batch := range p.Batches() {
+				batch.Release()
+			}
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/sketch"
+	"github.com/rs/zerolog/log"
+)
+
+// Deduper drops records repeating the postcode, recipe, delivery and, with
+// config.Extended, the customer_id of an earlier record, e.g. a delivery exported
+// twice after a retry upstream. The records are remembered in a Bloom filter of
+// fixed size, so a record can be dropped as a duplicate it is not with the
+// filter's false-positive rate, but a duplicate is never kept. A Deduper can be
+// shared by the parsers of one run, so duplicates across input files are dropped.
+type Deduper struct {
+	filter *sketch.Bloom
+	rate   float64
+	withID bool
+}
+
+// DedupeReport counts the records checked and dropped as duplicates in a Parse run,
+// with the false-positive rate of the filter at its end
+type DedupeReport struct {
+	Records           int     `json:"records"`
+	Dropped           int     `json:"dropped"`
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+	Bytes             int     `json:"bytes"`
+}
+
+func NewDeduper(cfg config.Config) (*Deduper, error) {
+	filter, err := sketch.NewBloom(cfg.DedupeCapacity, cfg.DedupeFalsePositive)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid dedupe settings")
+	}
+	return &Deduper{filter: filter, rate: cfg.DedupeFalsePositive, withID: cfg.Extended}, nil
+}
+
+// duplicate remembers the record and reports whether an equal one was seen before.
+// An aliased record is compared by the name it was read with, as two names of one
+// recipe are different records.
+func (d *Deduper) duplicate(recipe Recipe) bool {
+	name := recipe.Recipe
+	if recipe.Alias != "" {
+		name = recipe.Alias
+	}
+	var id string
+	if d.withID && recipe.Extra != nil {
+		id = recipe.Extra.CustomerID
+	}
+	return d.filter.AddHash(sketch.HashFields(recipe.Postcode, name, recipe.Delivery, id))
+}
+
+// MarshalBinary encodes the records remembered by the filter, e.g. to drop their
+// duplicates in a later run
+func (d *Deduper) MarshalBinary() ([]byte, error) {
+	return d.filter.MarshalBinary()
+}
+
+// UnmarshalBinary restores the records encoded by MarshalBinary. They must come
+// from a deduper of the same capacity and false-positive rate.
+func (d *Deduper) UnmarshalBinary(data []byte) error {
+	return errors.Wrap(d.filter.UnmarshalBinary(data), "failed to restore dedupe filter")
+}
+
+// report adds the state of the filter to the counts of a Parse run
+func (d *Deduper) report(counts DedupeReport) DedupeReport {
+	counts.FalsePositiveRate = d.filter.FalsePositiveRate()
+	counts.Bytes = d.filter.Bytes()
+	return counts
+}
+
+// Merge adds the counts of a later run, e.g. of the next file sharing the filter,
+// and takes its filter state
+func (r *DedupeReport) Merge(other DedupeReport) {
+	r.Records += other.Records
+	r.Dropped += other.Dropped
+	r.FalsePositiveRate = other.FalsePositiveRate
+	r.Bytes = other.Bytes
+}
+
+// log reports the duplicates dropped in a Parse run. Dropped records change the
+// counts, and a filter past its capacity drops records that are no duplicates at a
+// higher rate than configured, so both are warnings.
+func (d *Deduper) log(r DedupeReport) {
+	event := log.Info()
+	if r.Dropped > 0 || r.FalsePositiveRate > d.rate {
+		event = log.Warn()
+	}
+	event.
+		Int("records", r.Records).
+		Int("dropped", r.Dropped).
+		Float64("false_positive_rate", r.FalsePositiveRate).
+		Int("bytes", r.Bytes).
+		Msg("Dropped duplicate records")
+}
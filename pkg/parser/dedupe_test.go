@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
+)
+
+func TestJsonParser_Dedupe(t *testing.T) {
+	dedupe := config.Default().WithDedupe(true).WithLogRejections(0)
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		content  string
+		expected []string
+	}{
+		{
+			name: "exact duplicates",
+			cfg:  dedupe,
+			content: `[
+				{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM"},
+				{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM"},
+				{"postcode": "10224", "recipe": "Dill", "delivery": "Thursday 1AM - 7PM"},
+				{"postcode": "10120", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM"},
+				{"recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "postcode": "10224"}
+			]`,
+			expected: []string{"10224 Dill Wednesday 1AM - 7PM", "10224 Dill Thursday 1AM - 7PM", "10120 Dill Wednesday 1AM - 7PM"},
+		},
+		{
+			name: "not without dedupe",
+			cfg:  config.Default().WithLogRejections(0),
+			content: `[
+				{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM"},
+				{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM"}
+			]`,
+			expected: []string{"10224 Dill Wednesday 1AM - 7PM", "10224 Dill Wednesday 1AM - 7PM"},
+		},
+		{
+			name: "customer ids tell records apart",
+			cfg:  dedupe.WithExtended(true),
+			content: `[
+				{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "customer_id": "c1"},
+				{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "customer_id": "c2"},
+				{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "customer_id": "c1", "price": 9.5}
+			]`,
+			expected: []string{"10224 Dill Wednesday 1AM - 7PM", "10224 Dill Wednesday 1AM - 7PM"},
+		},
+		{
+			name: "normalized duplicates",
+			cfg:  dedupe.WithNormalize(true),
+			content: `[
+				{"postcode": "10224", "recipe": "Dill  Chicken", "delivery": "Wednesday 1AM - 7PM"},
+				{"postcode": " 10224", "recipe": "dill chicken", "delivery": "Wednesday 1AM - 7PM"}
+			]`,
+			expected: []string{"10224 Dill Chicken Wednesday 1AM - 7PM"},
+		},
+		{
+			name: "aliases are different records",
+			cfg:  dedupe.WithAliases("../../cmd/stats/testdata/aliases.yaml"),
+			content: `[
+				{"postcode": "10224", "recipe": "Speedy Fajitas", "delivery": "Wednesday 1AM - 7PM"},
+				{"postcode": "10224", "recipe": "Speedy Steak Fajitas", "delivery": "Wednesday 1AM - 7PM"},
+				{"postcode": "10224", "recipe": "Speedy Fajitas", "delivery": "Wednesday 1AM - 7PM"}
+			]`,
+			expected: []string{"10224 Speedy Steak Fajitas Wednesday 1AM - 7PM", "10224 Speedy Steak Fajitas Wednesday 1AM - 7PM"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			for _, engine := range []string{"json", "fast"} {
+				_, entries := parseWith(t, []byte(tt.content), tt.cfg.WithEngine(engine))
+				var got []string
+				for _, entry := range entries {
+					if entry.Error != nil {
+						t.Fatalf("Expected no error with the %s engine, but got %v", engine, entry.Error)
+					}
+					got = append(got, entry.Recipe.Postcode+" "+entry.Recipe.Recipe+" "+entry.Recipe.Delivery)
+				}
+				if !reflect.DeepEqual(got, tt.expected) {
+					t.Errorf("Expected %q with the %s engine, but got %q", tt.expected, engine, got)
+				}
+			}
+		})
+	}
+}
+
+func TestJsonParser_DedupeReport(t *testing.T) {
+	dir := t.TempDir()
+	record := `{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM"}`
+	for _, name := range []string{"a.json", "b.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(`[`+record+`, `+record+`]`), 0o644); err != nil {
+			t.Fatalf("Error writing input: %v", err)
+		}
+	}
+	cfg := config.Default().WithFile(dir).WithDedupe(true).WithDedupeCapacity(1000).WithLogRejections(0)
+
+	// the files of a directory share the filter
+	registry := metrics.NewRegistry()
+	p := NewJsonParser(cfg).WithMetrics(registry)
+	go p.Parse(context.Background())
+	for range p.Stream() {
+	}
+	got := p.Deduped()
+	if got.Records != 4 || got.Dropped != 3 || got.Bytes == 0 || got.FalsePositiveRate <= 0 || got.FalsePositiveRate > cfg.DedupeFalsePositive {
+		t.Errorf("Expected 3 of 4 records dropped with a false-positive rate below %v, but got %+v", cfg.DedupeFalsePositive, got)
+	}
+	expected := map[string]int64{metrics.ReasonDuplicate: 3}
+	if rejected := registry.Snapshot().RecordsRejected; !reflect.DeepEqual(rejected, expected) {
+		t.Errorf("Expected %v, but got %v", expected, rejected)
+	}
+
+	// a shared deduper drops the records seen by another parser
+	deduper, err := NewDeduper(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	var merged DedupeReport
+	for _, name := range []string{"a.json", "b.json"} {
+		p := NewJsonParser(cfg.WithFile(filepath.Join(dir, name))).WithDeduper(deduper)
+		go p.Parse(context.Background())
+		for range p.Stream() {
+		}
+		report := p.Report()
+		if report.Deduped == nil || report.Normalized != nil {
+			t.Fatalf("Expected only a dedupe report, but got %+v", report)
+		}
+		merged.Merge(*report.Deduped)
+	}
+	if merged.Records != 4 || merged.Dropped != 3 || merged.FalsePositiveRate != deduper.filter.FalsePositiveRate() {
+		t.Errorf("Expected 3 of 4 records dropped at the rate of the shared filter, but got %+v", merged)
+	}
+}
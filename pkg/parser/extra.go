@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxPrice bounds the price of a record, so the revenue in cents cannot overflow
+const maxPrice = 1e9
+
+// maxBoxSize bounds the box size of a record, so the box units cannot overflow
+const maxBoxSize = 1000
+
+// readExtra reads the optional fields of a raw record, skipping the keys the recipe
+// fields are read from. Keys match ignoring case and a repeated key overrides the
+// earlier one, like encoding/json does. A null record has no extra fields.
+func readExtra(raw []byte, skip []string) (*Extra, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, nil
+	}
+
+	extra := &Extra{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		if containsFold(skip, key) {
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "customer_id":
+			err = json.Unmarshal(value, &extra.CustomerID)
+		case "box_size":
+			err = json.Unmarshal(value, &extra.BoxSize)
+		case "order_date":
+			err = json.Unmarshal(value, &extra.OrderDate)
+		case "price":
+			err = json.Unmarshal(value, &extra.Price)
+		default:
+			if extra.Fields == nil {
+				extra.Fields = make(map[string]json.RawMessage)
+			}
+			extra.Fields[key] = value
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", key)
+		}
+	}
+	if extra.Price != nil && math.Abs(*extra.Price) > maxPrice {
+		return nil, errors.Errorf("price %v is out of range", *extra.Price)
+	}
+	if extra.BoxSize != nil && (*extra.BoxSize <= 0 || *extra.BoxSize > maxBoxSize) {
+		return nil, errors.Errorf("box_size %d is out of range", *extra.BoxSize)
+	}
+	return extra, nil
+}
+
+func containsFold(keys []string, key string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+)
+
+func TestJsonParser_Extra(t *testing.T) {
+	boxSize, price := 4, 35.5
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		content  string
+		expected []*Extra
+		errors   int
+	}{
+		{
+			name:     "known and other fields",
+			cfg:      config.Config{Extended: true},
+			content:  `[{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "Customer_ID": "c1", "box_size": 4, "order_date": "2024-01-22", "price": 35.5, "tags": ["veggie"]}]`,
+			expected: []*Extra{{CustomerID: "c1", BoxSize: &boxSize, OrderDate: "2024-01-22", Price: &price, Fields: map[string]json.RawMessage{"tags": json.RawMessage(`["veggie"]`)}}},
+		},
+		{
+			name:     "no extra fields",
+			cfg:      config.Config{Extended: true},
+			content:  `[` + validRecord + `]`,
+			expected: []*Extra{{}},
+		},
+		{
+			name:     "mapped fields are not extra",
+			cfg:      config.Config{Extended: true, FieldMapping: config.FieldMapping{Postcode: "/address/zip"}},
+			content:  `[{"address": {"zip": "10224"}, "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "box_size": null}]`,
+			expected: []*Extra{{}},
+		},
+		{
+			name:     "a repeated key overrides",
+			cfg:      config.Config{Extended: true},
+			content:  `[{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "box_size": 2, "BOX_SIZE": 4}]`,
+			expected: []*Extra{{BoxSize: &boxSize}},
+		},
+		{
+			name:     "a value of the wrong type skips the record",
+			cfg:      config.Config{Extended: true},
+			content:  `[{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "price": "35.50"}, ` + validRecord + `]`,
+			expected: []*Extra{{}},
+			errors:   1,
+		},
+		{
+			name:    "price out of range",
+			cfg:     config.Config{Extended: true},
+			content: `[{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "price": 1e300}]`,
+			errors:  1,
+		},
+		{
+			name:    "box size not positive",
+			cfg:     config.Config{Extended: true},
+			content: `[{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "box_size": 0}, {"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "box_size": -4}]`,
+			errors:  2,
+		},
+		{
+			name:    "box size out of range",
+			cfg:     config.Config{Extended: true},
+			content: `[{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "box_size": 9223372036854775807}]`,
+			errors:  1,
+		},
+		{
+			name:     "not read without extended",
+			content:  `[{"postcode": "10224", "recipe": "Dill", "delivery": "Wednesday 1AM - 7PM", "price": "35.50"}]`,
+			expected: []*Extra{nil},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			for _, engine := range []string{"json", "fast"} {
+				_, entries := parseWith(t, []byte(tt.content), tt.cfg.WithEngine(engine))
+				var extras []*Extra
+				errs := 0
+				for _, entry := range entries {
+					if entry.Error != nil {
+						errs++
+						continue
+					}
+					extras = append(extras, entry.Recipe.Extra)
+				}
+				if !reflect.DeepEqual(extras, tt.expected) || errs != tt.errors {
+					t.Errorf("Expected %+v and %v errors with the %s engine, but got %+v and %v errors", tt.expected, tt.errors, engine, extras, errs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,485 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
+)
+
+// maxInterned bounds the strings shared between records by the fast engine.
+// Recipe names and delivery windows are few, postcodes fill the rest.
+const maxInterned = 1 << 16
+
+// maxDepth limits the nesting of a record like encoding/json does, so a file of
+// brackets cannot exhaust the stack
+const maxDepth = 10000
+
+// parseFileFast streams the recipes of one file like parseFile, but scans the
+// memory-mapped file for the three recipe fields instead of decoding each record
+// with reflection. Repeated values share one string, so most records do not
+// allocate. It returns false once ctx is cancelled.
+func (r *JsonParser) parseFileFast(ctx context.Context, fileName string) bool {
+	data, unmap, err := mapFile(fileName)
+	if err != nil {
+		return r.send(ctx, Entry{Error: &InputError{File: fileName, Err: errors.Wrap(err, "failed to open file")}})
+	}
+	defer unmap()
+
+	if r.interned == nil {
+		r.interned = make(map[string]string)
+	}
+	s := &scanner{data: data, interned: r.interned}
+	r.progress.startFile(fileName, func() int64 { return int64(s.pos) })
+
+	// read opening delimiter `[`
+	if err := s.openArray(); err != nil {
+		return r.send(ctx, Entry{Error: &InputError{File: fileName, Err: errors.Wrap(err, "failed to read opening delimiter at offset 0")}})
+	}
+
+	for {
+		offset := int64(s.pos)
+		more, err := s.nextElement()
+		if err != nil {
+			// the rest of the file cannot be split into records
+			r.countRejected(metrics.ReasonDecode)
+			return r.send(ctx, Entry{Error: &DecodeError{File: fileName, Offset: offset, Err: errors.Wrap(err, "failed to decode recipe")}})
+		}
+		if !more {
+			break
+		}
+
+		offset = int64(s.pos)
+		recipe, err := s.recipe(&r.paths)
+		if err == nil {
+			recipe.Extra, err = r.readExtra(data[offset:s.pos])
+		}
+		if err != nil {
+			// the error is streamed, so only counted here
+			r.countRejected(metrics.ReasonDecode)
+			r.progress.record(true)
+			if !r.send(ctx, Entry{Error: &DecodeError{File: fileName, Offset: offset, Err: errors.Wrap(err, "failed to decode recipe")}}) {
+				return false
+			}
+			if s.broken {
+				return true
+			}
+			continue
+		}
+		recipe, ok := r.checkRecipe(recipe)
+		if !ok {
+			r.progress.record(true)
+			continue
+		}
+		r.metrics.RecordParsed()
+		r.progress.record(false)
+		if !r.send(ctx, Entry{Recipe: recipe}) {
+			return false
+		}
+	}
+
+	// read closing delimiter `]`
+	offset := int64(s.pos)
+	if err := s.closeArray(); err != nil {
+		return r.send(ctx, Entry{Error: &DecodeError{File: fileName, Offset: offset, Err: errors.Wrap(err, "failed to read closing delimiter")}})
+	}
+	return true
+}
+
+// scanner reads a JSON array of recipe objects from memory. It accepts the same
+// documents as encoding/json: keys match case-insensitively, unknown keys and null
+// values are skipped, and a value of the wrong type fails only its record.
+type scanner struct {
+	data     []byte
+	pos      int
+	started  bool
+	interned map[string]string
+	// depth is the nesting of the containers in the record
+	depth int
+	// broken is set by a syntax error, after which no record can be read
+	broken bool
+}
+
+func (s *scanner) syntaxError(msg string) error {
+	s.broken = true
+	if s.pos >= len(s.data) {
+		return errors.New("unexpected end of JSON input")
+	}
+	return errors.Errorf("invalid character %q %s at offset %d", s.data[s.pos], msg, s.pos)
+}
+
+func (s *scanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+// expect consumes c after optional whitespace
+func (s *scanner) expect(c byte, msg string) error {
+	s.skipSpace()
+	if s.pos >= len(s.data) || s.data[s.pos] != c {
+		return s.syntaxError(msg)
+	}
+	s.pos++
+	return nil
+}
+
+func (s *scanner) openArray() error {
+	return s.expect('[', "looking for beginning of array")
+}
+
+func (s *scanner) closeArray() error {
+	return s.expect(']', "after array element")
+}
+
+// nextElement consumes the comma before the next array element, it returns false
+// at the end of the array
+func (s *scanner) nextElement() (bool, error) {
+	s.skipSpace()
+	if s.pos >= len(s.data) {
+		return false, s.syntaxError("")
+	}
+	if s.data[s.pos] == ']' {
+		return false, nil
+	}
+	if s.started {
+		if err := s.expect(',', "after array element"); err != nil {
+			return false, err
+		}
+		s.skipSpace()
+	}
+	s.started = true
+	return true, nil
+}
+
+// Field indexes of a Recipe, in the order of config.FieldMapping.Paths
+const (
+	fieldRecipe = iota
+	fieldPostcode
+	fieldDelivery
+)
+
+// allFields is the mask of every recipe field
+const allFields = 1<<fieldRecipe | 1<<fieldPostcode | 1<<fieldDelivery
+
+func recipeField(recipe *Recipe, i int) *string {
+	switch i {
+	case fieldRecipe:
+		return &recipe.Recipe
+	case fieldPostcode:
+		return &recipe.Postcode
+	default:
+		return &recipe.Delivery
+	}
+}
+
+// recipe reads one array element into a Recipe, paths holds the keys leading to
+// each field
+func (s *scanner) recipe(paths *[3][]string) (Recipe, error) {
+	var recipe Recipe
+	if s.hasPrefix("null") {
+		// like encoding/json, a null record is an empty recipe
+		s.pos += len("null")
+		return recipe, nil
+	}
+	if s.pos >= len(s.data) || s.data[s.pos] != '{' {
+		start := s.pos
+		if err := s.skipValue(); err != nil {
+			return recipe, err
+		}
+		return recipe, errors.Errorf("cannot unmarshal %s into a recipe", s.data[start:s.pos])
+	}
+
+	var typeErr error
+	if err := s.container(paths, allFields, 0, &recipe, &typeErr); err != nil {
+		return recipe, err
+	}
+	return recipe, typeErr
+}
+
+// container reads the object or array at depth keys into the record, looking for
+// the fields of mask, whose paths lead through it
+func (s *scanner) container(paths *[3][]string, mask uint8, depth int, recipe *Recipe, typeErr *error) error {
+	end, object := byte(']'), false
+	if s.data[s.pos] == '{' {
+		end, object = '}', true
+	}
+	if err := s.enter(); err != nil {
+		return err
+	}
+	defer s.leave()
+	s.pos++
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == end {
+		s.pos++
+		return nil
+	}
+	for index := 0; ; index++ {
+		var key string
+		if object {
+			s.skipSpace()
+			var err error
+			if key, err = s.stringValue(true); err != nil {
+				return err
+			}
+			if err := s.expect(':', "after object key"); err != nil {
+				return err
+			}
+		} else {
+			key = strconv.Itoa(index)
+		}
+		s.skipSpace()
+
+		// the fields whose path continues with key, keys match ignoring case
+		var sub uint8
+		for i, path := range paths {
+			if mask&(1<<i) != 0 && len(path) > depth && strings.EqualFold(path[depth], key) {
+				sub |= 1 << i
+			}
+		}
+		if err := s.value(paths, sub, depth+1, recipe, typeErr); err != nil {
+			return err
+		}
+
+		s.skipSpace()
+		if s.pos >= len(s.data) {
+			return s.syntaxError("")
+		}
+		switch s.data[s.pos] {
+		case ',':
+			s.pos++
+		case end:
+			s.pos++
+			return nil
+		default:
+			if object {
+				return s.syntaxError("after object key:value pair")
+			}
+			return s.syntaxError("after array element")
+		}
+	}
+}
+
+// value reads the value at depth keys into the record, it is the field of mask
+// whose path ends here, or a container the paths of mask lead through
+func (s *scanner) value(paths *[3][]string, mask uint8, depth int, recipe *Recipe, typeErr *error) error {
+	if mask == 0 {
+		return s.skipValue()
+	}
+	// the config rejects overlapping paths, so no other field is inside this one
+	for i, path := range paths {
+		if mask&(1<<i) != 0 && len(path) == depth {
+			return s.field(recipeField(recipe, i), path[depth-1], typeErr)
+		}
+	}
+	if s.pos < len(s.data) && (s.data[s.pos] == '{' || s.data[s.pos] == '[') {
+		return s.container(paths, mask, depth, recipe, typeErr)
+	}
+	// like a missing key, a path through another value leaves the fields empty
+	return s.skipValue()
+}
+
+// field reads a string into field. Like encoding/json, null keeps it empty and a
+// value of another type is a type error of the record.
+func (s *scanner) field(field *string, key string, typeErr *error) error {
+	switch {
+	case s.pos < len(s.data) && s.data[s.pos] == '"':
+		value, err := s.stringValue(true)
+		if err != nil {
+			return err
+		}
+		*field = value
+	case s.hasPrefix("null"):
+		s.pos += len("null")
+	default:
+		start := s.pos
+		if err := s.skipValue(); err != nil {
+			return err
+		}
+		if *typeErr == nil {
+			*typeErr = errors.Errorf("cannot unmarshal %s into the %s string field", s.data[start:s.pos], key)
+		}
+	}
+	return nil
+}
+
+// enter counts a nested container, leave must follow when it is read
+func (s *scanner) enter() error {
+	s.depth++
+	if s.depth > maxDepth {
+		s.broken = true
+		return errors.New("exceeded max depth")
+	}
+	return nil
+}
+
+func (s *scanner) leave() {
+	s.depth--
+}
+
+// stringValue reads a JSON string. Strings with escapes or invalid UTF-8 are
+// decoded by encoding/json, the others are sliced from the file and, with intern,
+// shared between records.
+func (s *scanner) stringValue(intern bool) (string, error) {
+	if s.pos >= len(s.data) || s.data[s.pos] != '"' {
+		return "", s.syntaxError("looking for beginning of string")
+	}
+	start := s.pos
+	s.pos++
+	simple, ascii := true, true
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		switch {
+		case c == '"':
+			s.pos++
+			raw := s.data[start+1 : s.pos-1]
+			if !simple || (!ascii && !utf8.Valid(raw)) {
+				var value string
+				if err := json.Unmarshal(s.data[start:s.pos], &value); err != nil {
+					s.broken = true
+					return "", err
+				}
+				return value, nil
+			}
+			if intern {
+				return s.intern(raw), nil
+			}
+			return string(raw), nil
+		case c == '\\':
+			simple = false
+			// the escaped character cannot end the string
+			s.pos += 2
+		case c < 0x20:
+			return "", s.syntaxError("in string literal")
+		default:
+			if c >= utf8.RuneSelf {
+				ascii = false
+			}
+			s.pos++
+		}
+	}
+	s.pos = len(s.data)
+	return "", s.syntaxError("")
+}
+
+// intern returns a string equal to b, shared with earlier records where possible
+func (s *scanner) intern(b []byte) string {
+	// the map lookup with string(b) does not allocate
+	if value, ok := s.interned[string(b)]; ok {
+		return value
+	}
+	value := string(b)
+	if len(s.interned) < maxInterned {
+		s.interned[value] = value
+	}
+	return value
+}
+
+// skipValue skips any JSON value
+func (s *scanner) skipValue() error {
+	s.skipSpace()
+	if s.pos >= len(s.data) {
+		return s.syntaxError("")
+	}
+	switch c := s.data[s.pos]; {
+	case c == '"':
+		_, err := s.stringValue(false)
+		return err
+	case c == '{':
+		return s.skipContainer('}', true)
+	case c == '[':
+		return s.skipContainer(']', false)
+	case c == 't':
+		return s.literal("true")
+	case c == 'f':
+		return s.literal("false")
+	case c == 'n':
+		return s.literal("null")
+	case c == '-' || (c >= '0' && c <= '9'):
+		return s.number()
+	default:
+		return s.syntaxError("looking for beginning of value")
+	}
+}
+
+// skipContainer skips an object or array, the scanner is at its opening delimiter
+func (s *scanner) skipContainer(end byte, object bool) error {
+	if err := s.enter(); err != nil {
+		return err
+	}
+	defer s.leave()
+	s.pos++
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == end {
+		s.pos++
+		return nil
+	}
+	for {
+		if object {
+			s.skipSpace()
+			if _, err := s.stringValue(false); err != nil {
+				return err
+			}
+			if err := s.expect(':', "after object key"); err != nil {
+				return err
+			}
+		}
+		if err := s.skipValue(); err != nil {
+			return err
+		}
+		s.skipSpace()
+		if s.pos >= len(s.data) {
+			return s.syntaxError("")
+		}
+		switch s.data[s.pos] {
+		case ',':
+			s.pos++
+		case end:
+			s.pos++
+			return nil
+		default:
+			return s.syntaxError(fmt.Sprintf("looking for %q", end))
+		}
+	}
+}
+
+// hasPrefix reports whether the unread data starts with word
+func (s *scanner) hasPrefix(word string) bool {
+	// comparing with string(b) does not allocate
+	return len(s.data)-s.pos >= len(word) && string(s.data[s.pos:s.pos+len(word)]) == word
+}
+
+func (s *scanner) literal(word string) error {
+	if !s.hasPrefix(word) {
+		return s.syntaxError("in literal " + word)
+	}
+	s.pos += len(word)
+	return nil
+}
+
+// number skips a number, checking it with encoding/json
+func (s *scanner) number() error {
+	start := s.pos
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		if c != '-' && c != '+' && c != '.' && c != 'e' && c != 'E' && (c < '0' || c > '9') {
+			break
+		}
+		s.pos++
+	}
+	if !json.Valid(s.data[start:s.pos]) {
+		s.pos = start
+		return s.syntaxError("in numeric literal")
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+)
+
+func TestJsonParser_FastEngine(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileContent string
+	}{
+		{
+			name:        "Valid JSON Content",
+			fileContent: `[{"postcode": "12345", "delivery": "Monday 9AM - 5PM", "recipe": "RecipeA"}, {"postcode": "12345", "delivery": "Monday 10AM - 6PM", "recipe": "RecipeB"}]`,
+		},
+		{
+			name:        "Empty array",
+			fileContent: " [ ] ",
+		},
+		{
+			name:        "Keys in any case and unknown keys",
+			fileContent: `[{"Postcode": "12345", "DELIVERY": "Monday 9AM - 5PM", "extra": {"nested": [1, 2.5e3, true, null, "x"]}, "Recipe": "RecipeA", "count": -3}]`,
+		},
+		{
+			name:        "Escaped and unicode strings",
+			fileContent: `[{"postcode": "101", "delivery": "Monday 9AM - 5PM", "recipe": "Café \"Special\" Crème"}]`,
+		},
+		{
+			name:        "Null values and records",
+			fileContent: `[null, {"postcode": null, "delivery": "Monday 9AM - 5PM", "recipe": "RecipeA"}, {"postcode": "12345", "delivery": "Monday 9AM - 5PM", "recipe": "RecipeA"}]`,
+		},
+		{
+			name:        "Wrong value types",
+			fileContent: `[{"postcode": 12345, "delivery": "Monday 9AM - 5PM", "recipe": "RecipeA"}, 42, {"postcode": "12345", "delivery": "Monday 9AM - 5PM", "recipe": "RecipeB"}]`,
+		},
+		{
+			name:        "Rejected records",
+			fileContent: `[{"postcode": "", "delivery": "Monday 9AM - 5PM", "recipe": "RecipeA"}, {"postcode": "12345", "delivery": "InvalidTimeFormat", "recipe": "RecipeA"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := createTempJSONFile(t, tt.fileContent)
+			if err != nil {
+				t.Fatalf("Error creating temporary file: %v", err)
+			}
+			defer os.Remove(file.Name())
+
+			cfg := config.Config{File: file.Name()}
+			expected := collectEntries(NewJsonParser(cfg.WithEngine("json")))
+			got := collectEntries(NewJsonParser(cfg.WithEngine("fast")))
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected %v, but got %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestJsonParser_FastEngineSyntaxError(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileContent string
+		recipes     []string
+	}{
+		{name: "missing comma", fileContent: `[{"postcode": "1", "delivery": "Monday 9AM - 5PM", "recipe": "A"} {"recipe": "B"}]`, recipes: []string{"A"}},
+		{name: "truncated", fileContent: `[{"postcode": "1", "delivery": "Monday 9AM - 5PM", "recipe": "A"}, {"postcode": "1", "deliv`, recipes: []string{"A"}},
+		{name: "invalid value", fileContent: `[{"postcode": x}, {"postcode": "1", "delivery": "Monday 9AM - 5PM", "recipe": "A"}]`},
+		{name: "control character", fileContent: "[{\"postcode\": \"1\n\"}]"},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := createTempJSONFile(t, tt.fileContent)
+			if err != nil {
+				t.Fatalf("Error creating temporary file: %v", err)
+			}
+			defer os.Remove(file.Name())
+
+			entries := collectEntries(NewJsonParser(config.Config{File: file.Name(), Engine: "fast"}))
+			var recipes []string
+			errs := 0
+			for _, entry := range entries {
+				if entry.Error != "" {
+					errs++
+					continue
+				}
+				recipes = append(recipes, entry.Recipe.Recipe)
+			}
+			// the file is not read past the syntax error
+			if errs != 1 || !reflect.DeepEqual(recipes, tt.recipes) {
+				t.Errorf("Expected recipes %v and 1 error, but got %v", tt.recipes, entries)
+			}
+		})
+	}
+}
+
+// parsedEntry is an Entry whose error is reduced to its presence, the messages
+// of the engines differ
+type parsedEntry struct {
+	Recipe Recipe
+	Error  string
+}
+
+func collectEntries(p *JsonParser) []parsedEntry {
+	go p.Parse(context.Background())
+	var entries []parsedEntry
+	for entry := range p.Stream() {
+		entries = append(entries, toParsedEntry(entry))
+	}
+	return entries
+}
+
+func toParsedEntry(entry Entry) parsedEntry {
+	parsed := parsedEntry{Recipe: entry.Recipe}
+	if entry.Error != nil {
+		parsed.Error = "error"
+	}
+	return parsed
+}
+
+// benchmarkFixtures writes the test fixtures repeated n times to a temporary file
+func benchmarkFixtures(b testing.TB, n int) string {
+	content, err := os.ReadFile("../stats/testdata/test.json")
+	if err != nil {
+		b.Fatalf("Error reading fixtures: %v", err)
+	}
+	var recipes []json.RawMessage
+	if err := json.Unmarshal(content, &recipes); err != nil {
+		b.Fatalf("Error decoding fixtures: %v", err)
+	}
+	records := make([]string, 0, n*len(recipes))
+	for i := 0; i < n; i++ {
+		for _, recipe := range recipes {
+			records = append(records, string(recipe))
+		}
+	}
+
+	path := filepath.Join(b.TempDir(), "fixtures.json")
+	if err := os.WriteFile(path, []byte("[\n"+strings.Join(records, ",\n")+"\n]"), 0o644); err != nil {
+		b.Fatalf("Error writing fixtures: %v", err)
+	}
+	return path
+}
+
+func BenchmarkJsonParser(b *testing.B) {
+	for _, size := range []struct {
+		name    string
+		repeats int
+	}{{"fixtures", 1}, {"fixtures_x1000", 1000}} {
+		path := benchmarkFixtures(b, size.repeats)
+		info, err := os.Stat(path)
+		if err != nil {
+			b.Fatalf("Error reading fixtures: %v", err)
+		}
+		for _, engine := range []string{"json", "fast"} {
+			cfg := config.Config{File: path, Engine: engine}
+			b.Run(size.name+"/"+engine, func(b *testing.B) {
+				b.SetBytes(info.Size())
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					p := NewJsonParser(cfg)
+					go p.Parse(context.Background())
+					for range p.Stream() {
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// InputFiles returns the files to parse for path. A directory expands to the
+// .json files it contains, sorted by name, so exports named by date are read in order.
+func InputFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to stat input")
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read input directory")
+	}
+	var files []string
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.EqualFold(filepath.Ext(dirEntry.Name()), ".json") {
+			continue
+		}
+		files = append(files, filepath.Join(path, dirEntry.Name()))
+	}
+	slices.Sort(files)
+
+	return files, nil
+}
@@ -0,0 +1,266 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+)
+
+const validRecord = `{"postcode": "10224", "recipe": "Creamy Dill Chicken", "delivery": "Wednesday 1AM - 7PM"}`
+
+// parseBytes parses data with engine and returns the streamed entries. It fails the
+// test if Parse does not return or the stream is not closed in time.
+func parseBytes(t *testing.T, data []byte, engine string) (string, []Entry) {
+	t.Helper()
+	return parseWith(t, data, config.Config{Engine: engine})
+}
+
+// parseWith is parseBytes with the field mapping and extra fields of cfg
+func parseWith(t *testing.T, data []byte, cfg config.Config) (string, []Entry) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("Error writing input: %v", err)
+	}
+
+	engine := cfg.Engine
+	p := NewJsonParser(cfg.WithFile(path))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Parse(context.Background())
+	}()
+
+	collected := make(chan []Entry)
+	go func() {
+		var entries []Entry
+		for entry := range p.Stream() {
+			entries = append(entries, entry)
+		}
+		collected <- entries
+	}()
+
+	timeout := time.After(10 * time.Second)
+	var entries []Entry
+	select {
+	case entries = <-collected:
+	case <-timeout:
+		t.Fatalf("Expected the %s engine to close the stream, but it is still open for %q", engine, data)
+	}
+	select {
+	case <-done:
+	case <-timeout:
+		t.Fatalf("Expected the %s engine to return, but Parse is still running for %q", engine, data)
+	}
+	return path, entries
+}
+
+// checkEntries asserts the invariants of the entries streamed for any input: an
+// error is an InputError or a DecodeError within the file, a recipe passes sanitization
+func checkEntries(t *testing.T, path string, size int, entries []Entry) (recipes []Recipe, errs []error) {
+	t.Helper()
+	for _, entry := range entries {
+		if entry.Error == nil {
+			if entry.Recipe.Postcode == "" || len(entry.Recipe.Postcode) > 10 || entry.Recipe.Recipe == "" || len(entry.Recipe.Recipe) > 100 || !amPmPattern.MatchString(entry.Recipe.Delivery) {
+				t.Errorf("Expected a valid recipe, but got %+v", entry.Recipe)
+			}
+			recipes = append(recipes, entry.Recipe)
+			continue
+		}
+
+		var inputErr *InputError
+		var decodeErr *DecodeError
+		switch {
+		case errors.As(entry.Error, &inputErr):
+			if inputErr.File != path {
+				t.Errorf("Expected the error of %v, but got %v", path, inputErr)
+			}
+		case errors.As(entry.Error, &decodeErr):
+			if decodeErr.File != path || decodeErr.Offset < 0 || decodeErr.Offset > int64(size) {
+				t.Errorf("Expected an offset in 0..%v of %v, but got %v", size, path, decodeErr)
+			}
+		default:
+			t.Errorf("Expected an InputError or DecodeError, but got %v", entry.Error)
+		}
+		errs = append(errs, entry.Error)
+	}
+	return recipes, errs
+}
+
+func TestJsonParser_HostileInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		recipes int
+		errors  int
+		// input is set when the file is not an array at all
+		input bool
+	}{
+		{name: "empty file", content: "", errors: 1, input: true},
+		{name: "object", content: `{"postcode": "10224"}`, errors: 1, input: true},
+		{name: "number", content: `5`, errors: 1, input: true},
+		{name: "empty array", content: `[]`},
+		{name: "truncated record", content: `[` + validRecord + `, {"postcode": "1`, recipes: 1, errors: 1},
+		{name: "unclosed array", content: `[` + validRecord, recipes: 1, errors: 1},
+		{name: "syntax error stops the file", content: `[` + validRecord + `, {x}, ` + validRecord + `]`, recipes: 1, errors: 1},
+		{name: "type error skips the record", content: `[` + validRecord + `, {"postcode": 1}, ` + validRecord + `]`, recipes: 2, errors: 1},
+		{name: "record of the wrong type", content: `[1, "a", ` + validRecord + `]`, recipes: 1, errors: 2},
+		{name: "missing comma", content: `[` + validRecord + ` ` + validRecord + `]`, recipes: 1, errors: 1},
+		{name: "trailing comma", content: `[` + validRecord + `,]`, recipes: 1, errors: 1},
+		{name: "object closing the array", content: `[` + validRecord + `}`, recipes: 1, errors: 1},
+		{name: "deep nesting", content: `[{"tags": ` + strings.Repeat("[", 20000), errors: 1},
+		{name: "invalid utf-8", content: "[{\"postcode\": \"10224\", \"recipe\": \"Dill \xff\", \"delivery\": \"Wednesday 1AM - 7PM\"}]", recipes: 1},
+		{name: "trailing data", content: `[` + validRecord + `] garbage`, recipes: 1},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			for _, engine := range []string{"json", "fast"} {
+				path, entries := parseBytes(t, []byte(tt.content), engine)
+				recipes, errs := checkEntries(t, path, len(tt.content), entries)
+				if len(recipes) != tt.recipes || len(errs) != tt.errors {
+					t.Errorf("Expected %v recipes and %v errors with the %s engine, but got %v", tt.recipes, tt.errors, engine, entries)
+				}
+				var inputErr *InputError
+				if len(errs) > 0 && errors.As(errs[0], &inputErr) != tt.input {
+					t.Errorf("Expected input error %v with the %s engine, but got %v", tt.input, engine, errs[0])
+				}
+			}
+		})
+	}
+}
+
+func TestJsonParser_FieldMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   string
+		content  string
+		expected []Recipe
+		errors   int
+	}{
+		{
+			name:     "renamed keys",
+			fields:   "postcode=/zip,recipe=/meal_name,delivery=/slot",
+			content:  `[{"ZIP": "10224", "meal_name": "Dill Chicken", "slot": "Wednesday 1AM - 7PM", "recipe": "ignored"}]`,
+			expected: []Recipe{{Postcode: "10224", Recipe: "Dill Chicken", Delivery: "Wednesday 1AM - 7PM"}},
+		},
+		{
+			name:     "nested object and array index",
+			fields:   "postcode=/address/postcode,delivery=/slots/1",
+			content:  `[{"recipe": "Dill Chicken", "address": {"street": {"postcode": "x"}, "postcode": "10224"}, "slots": ["x", "Wednesday 1AM - 7PM"]}]`,
+			expected: []Recipe{{Postcode: "10224", Recipe: "Dill Chicken", Delivery: "Wednesday 1AM - 7PM"}},
+		},
+		{
+			name:     "escaped key",
+			fields:   "postcode=/post~1code,recipe=/a~0b",
+			content:  `[{"post/code": "10224", "a~b": "Dill Chicken", "delivery": "Wednesday 1AM - 7PM"}]`,
+			expected: []Recipe{{Postcode: "10224", Recipe: "Dill Chicken", Delivery: "Wednesday 1AM - 7PM"}},
+		},
+		{
+			name:    "missing and null paths leave the field empty",
+			fields:  "postcode=/address/postcode",
+			content: `[{"recipe": "A", "delivery": "Wednesday 1AM - 7PM", "address": null}, {"recipe": "A", "delivery": "Wednesday 1AM - 7PM", "address": "10224"}]`,
+		},
+		{
+			name:     "a value of the wrong type skips the record",
+			fields:   "postcode=/address/postcode",
+			content:  `[{"address": {"postcode": 10224}}, {"recipe": "A", "delivery": "Wednesday 1AM - 7PM", "address": {"postcode": "10224"}}]`,
+			expected: []Recipe{{Postcode: "10224", Recipe: "A", Delivery: "Wednesday 1AM - 7PM"}},
+			errors:   1,
+		},
+		{
+			name:    "overlapping paths",
+			fields:  "postcode=/address,delivery=/address/slot",
+			content: `[` + validRecord + `]`,
+			errors:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := config.ParseFieldMapping(tt.fields)
+			if err != nil {
+				t.Fatalf("Error parsing fields: %v", err)
+			}
+			for _, engine := range []string{"json", "fast"} {
+				_, entries := parseWith(t, []byte(tt.content), config.Config{Engine: engine, FieldMapping: fields})
+				var recipes []Recipe
+				var errs []error
+				for _, entry := range entries {
+					if entry.Error != nil {
+						errs = append(errs, entry.Error)
+						continue
+					}
+					recipes = append(recipes, entry.Recipe)
+				}
+				if !reflect.DeepEqual(recipes, tt.expected) || len(errs) != tt.errors {
+					t.Errorf("Expected %v and %v errors with the %s engine, but got %v %v", tt.expected, tt.errors, engine, recipes, errs)
+				}
+			}
+		})
+	}
+}
+
+func TestJsonParser_DecodeErrorOffset(t *testing.T) {
+	content := `[` + validRecord + `, {"postcode": 1}]`
+	for _, engine := range []string{"json", "fast"} {
+		_, entries := parseBytes(t, []byte(content), engine)
+		var decodeErr *DecodeError
+		if len(entries) != 2 || !errors.As(entries[1].Error, &decodeErr) {
+			t.Fatalf("Expected a recipe and a DecodeError with the %s engine, but got %v", engine, entries)
+		}
+		// the record starts after the separator, the json engine reports the separator
+		start := int64(strings.Index(content, `{"postcode": 1}`))
+		if decodeErr.Offset < start-2 || decodeErr.Offset > start {
+			t.Errorf("Expected offset %v with the %s engine, but got %v", start, engine, decodeErr.Offset)
+		}
+	}
+}
+
+// FuzzJsonParser_Parse feeds arbitrary bytes to both engines, run it with
+// go test ./pkg/parser -fuzz JsonParser_Parse
+func FuzzJsonParser_Parse(f *testing.F) {
+	f.Add([]byte(`[` + validRecord + `]`))
+	f.Add([]byte(`[` + validRecord + `, {"postcode": 1}, null, {"recipe": "é"}]`))
+	f.Add([]byte(`[` + validRecord + `, {x}`))
+	f.Add([]byte(`{"a": [1, 2]}`))
+	f.Add([]byte(`[[[[{"a": -1.5e3, "b": true, "c": null}]]]]`))
+	f.Add([]byte(`[{"meal": "Dill", "address": {"zip": "10224"}, "slots": ["Wednesday 1AM - 7PM", 1]}]`))
+	f.Add([]byte(`[` + validRecord[:len(validRecord)-1] + `, "box_size": 2, "Price": 9.5, "tags": [1]}]`))
+	f.Add([]byte(`[{"postcode": " 1024", "recipe": "DILL  chicken", "delivery": "Wednesday 1AM - 7PM"}, {"postcode": "10224", "recipe": "dill chicken", "delivery": "Wednesday 1AM - 7PM"}]`))
+	// the default keys, nested paths through objects and arrays, extra fields,
+	// normalization and dedupe
+	modes := []config.Config{
+		{},
+		{FieldMapping: config.FieldMapping{Recipe: "/meal", Postcode: "/address/zip", Delivery: "/slots/0"}},
+		{Extended: true},
+		{Normalize: true, NormalizePostcodeWidth: 5},
+		{Dedupe: true, DedupeCapacity: 100, DedupeFalsePositive: 0.01},
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, cfg := range modes {
+			var results [][]Recipe
+			var errCounts []int
+			for _, engine := range []string{"json", "fast"} {
+				path, entries := parseWith(t, data, cfg.WithEngine(engine))
+				recipes, errs := checkEntries(t, path, len(data), entries)
+				results = append(results, recipes)
+				errCounts = append(errCounts, len(errs))
+			}
+
+			// the engines agree on the recipes, and on whether the file has errors
+			if !reflect.DeepEqual(results[0], results[1]) || (errCounts[0] == 0) != (errCounts[1] == 0) {
+				t.Errorf("Expected the same recipes and errors with %+v, but got %v with %v errors and %v with %v errors for %q", cfg, results[0], errCounts[0], results[1], errCounts[1], data)
+			}
+		}
+	})
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package parser
+
+import "os"
+
+// mapFile reads the whole file where mmap is not available
+func mapFile(fileName string) (data []byte, unmap func() error, err error) {
+	data, err = os.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package parser
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// mapFile maps the file read-only into memory. The returned unmap releases it,
+// no byte of data may be used afterwards.
+func mapFile(fileName string) (data []byte, unmap func() error, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the mapping stays valid once the file is closed
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		// mmap fails on empty files
+		return nil, func() error { return nil }, nil
+	}
+	if size != int64(int(size)) {
+		return nil, nil, errors.Errorf("file too large to map: %d bytes", size)
+	}
+
+	data, err = syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to map file")
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization changes counted in NormalizeReport
+const (
+	changeWhitespace = 1 << iota
+	changeUnicode
+	changeCase
+	changePadding
+)
+
+// maxNormalized bounds the recipe name variants whose display name is cached
+const maxNormalized = 1 << 16
+
+// Normalizer cleans up recipe names and postcodes before they are checked and
+// counted: it trims and collapses whitespace, composes Unicode to NFC and pads
+// numeric postcodes with zeros. Recipe names differing only in case are grouped
+// under the first variant accepted, their display name. A Normalizer can be
+// shared by the parsers of one run, so they agree on the display names.
+type Normalizer struct {
+	postcodeWidth int
+	fold          cases.Caser
+	// names maps the folded recipe name to its display name
+	names map[string]string
+	// variants caches the display name of a raw recipe name
+	variants map[string]string
+}
+
+// NormalizeReport counts the records changed by normalization, and by kind of
+// change, a record can have several
+type NormalizeReport struct {
+	Records    int `json:"records"`
+	Whitespace int `json:"whitespace"`
+	Unicode    int `json:"unicode"`
+	Case       int `json:"case"`
+	Padding    int `json:"padding"`
+}
+
+func NewNormalizer(cfg config.Config) *Normalizer {
+	return &Normalizer{
+		postcodeWidth: cfg.NormalizePostcodeWidth,
+		fold:          cases.Fold(),
+		names:         make(map[string]string),
+		variants:      make(map[string]string),
+	}
+}
+
+// WithNames makes names the display names of their variants, e.g. the recipes of a
+// persisted state, so a later run counts its variants under the same names
+func (n *Normalizer) WithNames(names []string) *Normalizer {
+	for _, name := range names {
+		key := n.fold.String(name)
+		if _, ok := n.names[key]; !ok {
+			n.names[key] = name
+		}
+	}
+	return n
+}
+
+// normalize cleans the recipe name and postcode, the name is grouped by accept once
+// the recipe passed the rules. It returns the kinds of changes.
+func (n *Normalizer) normalize(recipe Recipe) (Recipe, int) {
+	name, nameChanges := cleanText(recipe.Recipe)
+	postcode, postcodeChanges := cleanText(recipe.Postcode)
+	if n.postcodeWidth > len(postcode) && isDigits(postcode) {
+		postcode = strings.Repeat("0", n.postcodeWidth-len(postcode)) + postcode
+		postcodeChanges |= changePadding
+	}
+	recipe.Recipe, recipe.Postcode = name, postcode
+	return recipe, nameChanges | postcodeChanges
+}
+
+// accept groups the name of a recipe that passed the rules under its display name.
+// Only accepted names become display names, so a rejected variant is never shown.
+func (n *Normalizer) accept(recipe Recipe, changes int) (Recipe, int) {
+	display, ok := n.variants[recipe.Recipe]
+	if !ok {
+		key := n.fold.String(recipe.Recipe)
+		if display, ok = n.names[key]; !ok {
+			display = recipe.Recipe
+			n.names[key] = display
+		}
+		if len(n.variants) < maxNormalized {
+			n.variants[recipe.Recipe] = display
+		}
+	}
+	if display != recipe.Recipe {
+		changes |= changeCase
+		recipe.Recipe = display
+	}
+	return recipe, changes
+}
+
+// add counts the changes of one record
+func (r *NormalizeReport) add(changes int) {
+	if changes == 0 {
+		return
+	}
+	r.Records++
+	if changes&changeWhitespace != 0 {
+		r.Whitespace++
+	}
+	if changes&changeUnicode != 0 {
+		r.Unicode++
+	}
+	if changes&changeCase != 0 {
+		r.Case++
+	}
+	if changes&changePadding != 0 {
+		r.Padding++
+	}
+}
+
+// Merge adds the counts of other, e.g. of an earlier run
+func (r *NormalizeReport) Merge(other NormalizeReport) {
+	r.Records += other.Records
+	r.Whitespace += other.Whitespace
+	r.Unicode += other.Unicode
+	r.Case += other.Case
+	r.Padding += other.Padding
+}
+
+// log reports the changed records of a Parse run
+func (r NormalizeReport) log() {
+	log.Info().
+		Int("records", r.Records).
+		Int("whitespace", r.Whitespace).
+		Int("unicode", r.Unicode).
+		Int("case", r.Case).
+		Int("padding", r.Padding).
+		Msg("Normalized records")
+}
+
+// cleanText trims and collapses whitespace and composes s to NFC. Clean text, the
+// common case, is returned as is without allocating.
+func cleanText(s string) (string, int) {
+	var changes int
+	if !isCleanSpace(s) {
+		s = strings.Join(strings.Fields(s), " ")
+		changes |= changeWhitespace
+	}
+	if !norm.NFC.IsNormalString(s) {
+		s = norm.NFC.String(s)
+		changes |= changeUnicode
+	}
+	return s, changes
+}
+
+// isCleanSpace reports whether s has no leading, trailing or repeated whitespace,
+// and no whitespace but single spaces
+func isCleanSpace(s string) bool {
+	previous := ' '
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if r != ' ' || previous == ' ' {
+				return false
+			}
+		}
+		previous = r
+	}
+	last, _ := utf8.DecodeLastRuneInString(s)
+	return !unicode.IsSpace(last)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+)
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		changes  int
+	}{
+		{name: "clean", input: "Tex-Mex Tilapia", expected: "Tex-Mex Tilapia"},
+		{name: "empty", input: "", expected: ""},
+		{name: "padded", input: "  Tex-Mex Tilapia\t", expected: "Tex-Mex Tilapia", changes: changeWhitespace},
+		{name: "repeated spaces", input: "Tex-Mex   Tilapia", expected: "Tex-Mex Tilapia", changes: changeWhitespace},
+		{name: "non-breaking space", input: "Tex-Mex\u00a0Tilapia", expected: "Tex-Mex Tilapia", changes: changeWhitespace},
+		{name: "decomposed", input: "Cafe\u0301 Steak", expected: "Café Steak", changes: changeUnicode},
+		{name: "both", input: " Cafe\u0301  Steak", expected: "Café Steak", changes: changeWhitespace | changeUnicode},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			actual, changes := cleanText(tt.input)
+			if actual != tt.expected || changes != tt.changes {
+				t.Errorf("Expected %q with changes %b, but got %q with changes %b", tt.expected, tt.changes, actual, changes)
+			}
+		})
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	n := NewNormalizer(config.Config{NormalizePostcodeWidth: 5}).WithNames([]string{"Tex-Mex Tilapia"})
+
+	tests := []struct {
+		name     string
+		input    Recipe
+		expected Recipe
+		changes  int
+	}{
+		{
+			name:     "unchanged",
+			input:    Recipe{Postcode: "10224", Recipe: "Tex-Mex Tilapia"},
+			expected: Recipe{Postcode: "10224", Recipe: "Tex-Mex Tilapia"},
+		},
+		{
+			name:     "case variant of a known name",
+			input:    Recipe{Postcode: "10224", Recipe: "TEX-MEX  tilapia"},
+			expected: Recipe{Postcode: "10224", Recipe: "Tex-Mex Tilapia"},
+			changes:  changeWhitespace | changeCase,
+		},
+		{
+			name:     "first variant is the display name",
+			input:    Recipe{Postcode: "10224", Recipe: "Speedy Steak Fajitas"},
+			expected: Recipe{Postcode: "10224", Recipe: "Speedy Steak Fajitas"},
+		},
+		{
+			name:     "later variant",
+			input:    Recipe{Postcode: "10224", Recipe: "speedy steak fajitas"},
+			expected: Recipe{Postcode: "10224", Recipe: "Speedy Steak Fajitas"},
+			changes:  changeCase,
+		},
+		{
+			name:     "short numeric postcode",
+			input:    Recipe{Postcode: " 1024", Recipe: "Tex-Mex Tilapia"},
+			expected: Recipe{Postcode: "01024", Recipe: "Tex-Mex Tilapia"},
+			changes:  changeWhitespace | changePadding,
+		},
+		{
+			name:     "short alphanumeric postcode",
+			input:    Recipe{Postcode: "A12", Recipe: "Tex-Mex Tilapia"},
+			expected: Recipe{Postcode: "A12", Recipe: "Tex-Mex Tilapia"},
+		},
+	}
+
+	for _, tt := range tests {
+		// no parallel subtests, the display names depend on the order
+		recipe, changes := n.normalize(tt.input)
+		recipe, changes = n.accept(recipe, changes)
+		if recipe != tt.expected || changes != tt.changes {
+			t.Errorf("%s: Expected %+v with changes %b, but got %+v with changes %b", tt.name, tt.expected, tt.changes, recipe, changes)
+		}
+	}
+}
+
+func TestJsonParser_Normalize(t *testing.T) {
+	content := `[
+		{"postcode": "10224", "recipe": "TEX-MEX TILAPIA", "delivery": "Wednesday 1AM"},
+		{"postcode": " 10224", "recipe": "tex-mex  tilapia", "delivery": "Wednesday 1AM - 7PM"},
+		{"postcode": "10224", "recipe": "Tex-Mex Tilapia", "delivery": "Wednesday 1AM - 7PM"},
+		{"postcode": "10224", "recipe": "Cafe\u0301 Steak", "delivery": "Wednesday 1AM - 7PM"},
+		{"postcode": "10224", "recipe": "Café Steak", "delivery": "Wednesday 1AM - 7PM"}
+	]`
+	expected := []string{"tex-mex tilapia", "tex-mex tilapia", "Café Steak", "Café Steak"}
+
+	for _, engine := range []string{"json", "fast"} {
+		_, entries := parseWith(t, []byte(content), config.Config{Normalize: true}.WithEngine(engine))
+		var names []string
+		for _, entry := range entries {
+			if entry.Error != nil {
+				t.Fatalf("Expected no error with the %s engine, but got %v", engine, entry.Error)
+			}
+			names = append(names, entry.Recipe.Recipe)
+			if entry.Recipe.Postcode != "10224" {
+				t.Errorf("Expected postcode 10224 with the %s engine, but got %q", engine, entry.Recipe.Postcode)
+			}
+		}
+		// the rejected first variant never becomes the display name
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("Expected %q with the %s engine, but got %q", expected, engine, names)
+		}
+	}
+}
+
+func TestJsonParser_NormalizeReport(t *testing.T) {
+	p := NewJsonParser(config.Config{Normalize: true, NormalizePostcodeWidth: 5})
+	for _, recipe := range []Recipe{
+		{Postcode: "1024", Recipe: "Tex-Mex Tilapia", Delivery: "Wednesday 1AM - 7PM"},
+		{Postcode: "10224", Recipe: " tex-mex tilapia", Delivery: "Wednesday 1AM - 7PM"},
+		{Postcode: "10224", Recipe: "Tex-Mex Tilapia", Delivery: "Wednesday 1AM - 7PM"},
+		{Postcode: "10224", Recipe: "Cafe\u0301 Steak", Delivery: "Wednesday 1AM - 7PM"},
+		{Postcode: "102245678901", Recipe: "  Café Steak", Delivery: "Wednesday 1AM - 7PM"},
+	} {
+		p.checkRecipe(recipe)
+	}
+
+	// the rejected record is not counted
+	expected := NormalizeReport{Records: 3, Whitespace: 1, Unicode: 1, Case: 1, Padding: 1}
+	if actual := p.Normalized(); actual != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, actual)
+	}
+	if actual := p.Report().Normalized; actual == nil || *actual != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, actual)
+	}
+}
@@ -1,108 +1,490 @@
 package parser
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"regexp"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+var (
+	// deliveryPattern matches a delivery like "Monday 9AM - 5PM" or "Monday 2PM - 8PM"
+	deliveryPattern = regexp.MustCompile(`^(\w+)\s+([1-9]|1[0-2])\s*(AM|PM)\s*-\s*([1-9]|1[0-2])\s*(AM|PM)$`)
+	// amPmPattern only matches deliveries from an AM to a PM hour, the default rule
+	amPmPattern = regexp.MustCompile(`^(\w+)\s+([1-9]|1[0-2])\s*(AM)\s*-\s*([1-9]|1[0-2])\s*(PM)$`)
+)
+
+// ParseDelivery returns the weekday and the start and end hour of the day of a
+// delivery like "Monday 9AM - 5PM", in any spacing the parser accepts
+func ParseDelivery(delivery string) (day string, start, end int, err error) {
+	match := deliveryPattern.FindStringSubmatch(delivery)
+	if match == nil {
+		return "", 0, 0, errors.Errorf("invalid delivery %q", delivery)
+	}
+	if start, err = config.ParseHour(match[2] + match[3]); err != nil {
+		return "", 0, 0, err
+	}
+	if end, err = config.ParseHour(match[4] + match[5]); err != nil {
+		return "", 0, 0, err
+	}
+	return match[1], start, end, nil
+}
+
 type Parser interface {
-	Parse()
+	// Parse streams the entries and closes the stream when done. It returns early,
+	// still closing the stream, once ctx is cancelled.
+	Parse(ctx context.Context)
+	// Stream returns the entries one by one
 	Stream() <-chan Entry
+	// Batches returns the entries in batches, to be released once processed. A
+	// consumer reads either Stream or Batches.
+	Batches() <-chan *Batch
+}
+
+// Reporter is implemented by parsers reporting on the records they changed or
+// dropped, the report is complete once the stream is closed
+type Reporter interface {
+	Report() Report
+}
+
+// Report of a Parse run, the parts of disabled modes are nil
+type Report struct {
+	Normalized *NormalizeReport `json:"normalized,omitempty"`
+	Deduped    *DedupeReport    `json:"dedupe,omitempty"`
 }
 
 type JsonParser struct {
-	cfg    config.Config
-	stream chan Entry
+	cfg              config.Config
+	batches          chan *Batch
+	batch            *Batch
+	stream           chan Entry
+	streamOnce       sync.Once
+	parseOnce        sync.Once
+	done             <-chan struct{}
+	progressFunc     ProgressFunc
+	progressInterval time.Duration
+	progress         *progressTracker
+	metrics          metrics.Metrics
+	// rejectLog is sampled, rejected counts the rejections by reason for the summary
+	rejectLog zerolog.Logger
+	rejected  map[string]int
+	// interned shares repeated strings between records of the fast engine
+	interned map[string]string
+	// paths lead to the recipe fields, mapped is set when they are not the default keys
+	paths  [3][]string
+	mapped bool
+	// rules are the sanitization checks, in order
+	rules []Rule
+	// cfgErr is an invalid field mapping or rule, Parse streams it instead of reading
+	cfgErr error
+	// normalizer is set with config.Normalize, normalized counts its changes
+	normalizer *Normalizer
+	normalized NormalizeReport
+	// aliases map variant recipe names to their canonical name
+	aliases config.Aliases
+	// deduper is created by Parse with config.Dedupe, unless set by WithDeduper.
+	// deduped counts its drops.
+	deduper *Deduper
+	deduped DedupeReport
+	// recipeKeys are the keys of a record the recipe fields are read from, the
+	// other keys are its extra fields
+	recipeKeys []string
 }
 
 func NewJsonParser(cfg config.Config) *JsonParser {
+	paths, err := cfg.FieldMapping.Paths()
+	if err != nil {
+		err = errors.Wrap(err, "invalid field mapping")
+	}
+	rules, rulesErr := NewRules(cfg.Rules)
+	if err == nil && rulesErr != nil {
+		err = errors.Wrap(rulesErr, "invalid rules")
+	}
+	aliases, aliasesErr := cfg.LoadAliases()
+	if err == nil && aliasesErr != nil {
+		err = aliasesErr
+	}
+	recipeKeys := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if len(path) > 0 {
+			recipeKeys = append(recipeKeys, path[0])
+		}
+	}
+	var normalizer *Normalizer
+	if cfg.Normalize {
+		normalizer = NewNormalizer(cfg).WithNames(aliases.Names())
+	}
 	return &JsonParser{
-		cfg:    cfg,
-		stream: make(chan Entry),
+		cfg:        cfg,
+		batches:    make(chan *Batch),
+		stream:     make(chan Entry),
+		metrics:    metrics.Nop{},
+		rejectLog:  rejectionLogger(cfg.LogRejections),
+		rejected:   make(map[string]int),
+		paths:      paths,
+		mapped:     !cfg.FieldMapping.IsDefault(),
+		rules:      rules,
+		cfgErr:     err,
+		normalizer: normalizer,
+		aliases:    aliases,
+		recipeKeys: recipeKeys,
 	}
 }
 
+// rejectionLogger logs at most perSecond rejected records per second, so a file
+// with millions of bad records does not flood the logs
+func rejectionLogger(perSecond int) zerolog.Logger {
+	if perSecond <= 0 {
+		return zerolog.Nop()
+	}
+	return log.Logger.Sample(&zerolog.BurstSampler{Burst: uint32(perSecond), Period: time.Second})
+}
+
+// WithMetrics counts parsed and rejected records and times Parse in m
+func (r *JsonParser) WithMetrics(m metrics.Metrics) *JsonParser {
+	r.metrics = m
+	return r
+}
+
+// WithRules adds rules to the ones of the config, they are checked last
+func (r *JsonParser) WithRules(rules ...Rule) *JsonParser {
+	r.rules = append(r.rules, rules...)
+	return r
+}
+
+// WithNormalizer normalizes the recipes with n, e.g. one shared by the parsers of
+// several input files. The names of the aliases become display names of n.
+func (r *JsonParser) WithNormalizer(n *Normalizer) *JsonParser {
+	if n != nil {
+		n.WithNames(r.aliases.Names())
+	}
+	r.normalizer = n
+	return r
+}
+
+// WithDeduper drops duplicates with d, e.g. one shared by the parsers of several
+// input files
+func (r *JsonParser) WithDeduper(d *Deduper) *JsonParser {
+	r.deduper = d
+	return r
+}
+
+// WithProgress makes Parse call fn at most once per interval while reading, and once
+// more with Progress.Done set when it returns
+func (r *JsonParser) WithProgress(fn ProgressFunc, interval time.Duration) *JsonParser {
+	r.progressFunc = fn
+	r.progressInterval = interval
+	return r
+}
+
+// Stream unpacks the batches into single entries, an adapter for consumers that
+// do not need the throughput of Batches
 func (r *JsonParser) Stream() <-chan Entry {
+	r.streamOnce.Do(func() {
+		go r.unbatch()
+	})
 	return r.stream
 }
 
-// Parse reads the JSON file and streams Recipe objects over the channel
-func (r *JsonParser) Parse() {
-	defer close(r.stream)
+func (r *JsonParser) Batches() <-chan *Batch {
+	return r.batches
+}
 
-	file, err := os.Open(r.cfg.File)
+// Parse reads the JSON file, or every file of a directory, and streams Recipe
+// objects over the channel until ctx is cancelled. The stream is closed once, so a
+// later call returns right away.
+func (r *JsonParser) Parse(ctx context.Context) {
+	r.parseOnce.Do(func() {
+		r.parse(ctx)
+	})
+}
+
+func (r *JsonParser) parse(ctx context.Context) {
+	defer close(r.batches)
+	// stops the Stream adapter, also when ctx is cancelled after Parse returned. It
+	// is set before the first batch is sent, which unbatch receives before reading
+	// it, and nothing is registered on ctx, so a long-lived ctx does not keep the
+	// parser reachable.
+	r.done = ctx.Done()
+	defer r.flush(ctx)
+	start := time.Now()
+	defer func() {
+		r.metrics.ObserveParse(time.Since(start))
+	}()
+	clear(r.rejected)
+	defer r.logRejectedSummary()
+	if r.normalizer != nil {
+		r.normalized = NormalizeReport{}
+		defer func() { r.normalized.log() }()
+	}
+
+	if r.cfgErr != nil {
+		r.send(ctx, Entry{Error: &InputError{File: r.cfg.File, Err: r.cfgErr}})
+		return
+	}
+	// the filter is only allocated here, a deduper set by WithDeduper replaces it
+	if r.cfg.Dedupe && r.deduper == nil {
+		deduper, err := NewDeduper(r.cfg)
+		if err != nil {
+			r.send(ctx, Entry{Error: &InputError{File: r.cfg.File, Err: err}})
+			return
+		}
+		r.deduper = deduper
+	}
+	if r.deduper != nil {
+		r.deduped = DedupeReport{}
+		defer func() { r.deduper.log(r.deduper.report(r.deduped)) }()
+	}
+	files, err := InputFiles(r.cfg.File)
 	if err != nil {
-		r.stream <- Entry{Error: errors.Wrap(err, "failed to open file")}
+		r.send(ctx, Entry{Error: &InputError{File: r.cfg.File, Err: err}})
 		return
 	}
+	r.progress = newProgressTracker(r.progressFunc, r.progressInterval, files)
+	defer r.progress.report(true)
+
+	for _, fileName := range files {
+		if !r.parseFile(ctx, fileName) {
+			return
+		}
+	}
+}
+
+// parseFile streams the recipes of one file, it returns false once ctx is cancelled
+func (r *JsonParser) parseFile(ctx context.Context, fileName string) bool {
+	if r.cfg.Engine == "fast" {
+		return r.parseFileFast(ctx, fileName)
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return r.send(ctx, Entry{Error: &InputError{File: fileName, Err: errors.Wrap(err, "failed to open file")}})
+	}
 	defer file.Close()
 
-	decoder := json.NewDecoder(file)
+	decoder := json.NewDecoder(r.progress.wrap(fileName, file))
 	// read opening delimiter `[`
-	if _, err := decoder.Token(); err != nil {
-		r.stream <- Entry{Error: errors.Wrap(err, "failed to read opening delimiter")}
+	token, err := decoder.Token()
+	if err == nil && token != json.Delim('[') {
+		err = errors.Errorf("expected an array, got %v", token)
+	}
+	if err != nil {
+		return r.send(ctx, Entry{Error: &InputError{File: fileName, Err: errors.Wrap(err, "failed to read opening delimiter at offset 0")}})
 	}
 
 	for decoder.More() {
-		var recipe Recipe
+		offset := decoder.InputOffset()
 		// decode an array value (Recipe)
-		if err := decoder.Decode(&recipe); err != nil {
-			r.stream <- Entry{Error: errors.Wrap(err, "failed to decode recipe")}
+		recipe, skip, err := r.decodeRecipe(decoder)
+		if err != nil {
+			// the error is streamed, so only counted here
+			r.countRejected(metrics.ReasonDecode)
+			r.progress.record(true)
+			if !r.send(ctx, Entry{Error: &DecodeError{File: fileName, Offset: offset, Err: errors.Wrap(err, "failed to decode recipe")}}) {
+				return false
+			}
+			if !skip {
+				return true
+			}
+			continue
 		}
-		if !r.sanitizeRecipe(recipe) {
+		recipe, ok := r.checkRecipe(recipe)
+		if !ok {
+			r.progress.record(true)
 			continue
 		}
-		r.stream <- Entry{Recipe: recipe}
+		r.metrics.RecordParsed()
+		r.progress.record(false)
+		if !r.send(ctx, Entry{Recipe: recipe}) {
+			return false
+		}
 	}
 
 	// read closing delimiter `]`
+	offset := decoder.InputOffset()
 	if _, err := decoder.Token(); err != nil {
-		r.stream <- Entry{Error: errors.Wrap(err, "failed to read closing delimiter")}
+		return r.send(ctx, Entry{Error: &DecodeError{File: fileName, Offset: offset, Err: errors.Wrap(err, "failed to read closing delimiter")}})
 	}
+	return true
 }
 
-func (r *JsonParser) sanitizeRecipe(recipe Recipe) bool {
-	// postcode is not empty
-	if recipe.Postcode == "" {
-		log.Error().Msg("postcode is empty")
-		return false
+// decodeRecipe decodes the next array value, skip reports whether a failed record
+// can be skipped or the rest of the file is lost. With a field mapping or extra
+// fields the record is read raw and its fields are found like the fast engine
+// does, so both engines agree on every input.
+func (r *JsonParser) decodeRecipe(decoder *json.Decoder) (recipe Recipe, skip bool, err error) {
+	if !r.mapped && !r.cfg.Extended {
+		err = decoder.Decode(&recipe)
+		// a value of the wrong type is skipped, any other error sticks to the decoder
+		var typeErr *json.UnmarshalTypeError
+		return recipe, errors.As(err, &typeErr), err
 	}
-	// postcode is less than 10 characters
-	if len(recipe.Postcode) > 10 {
-		log.Error().Msg("postcode is longer than 10 characters")
-		return false
+
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return recipe, false, err
+	}
+	if r.interned == nil {
+		r.interned = make(map[string]string)
+	}
+	s := &scanner{data: raw, interned: r.interned}
+	// the raw record is valid JSON, so any error is a value of the wrong type
+	if recipe, err = s.recipe(&r.paths); err != nil {
+		return recipe, true, err
 	}
+	recipe.Extra, err = r.readExtra(raw)
+	return recipe, true, err
+}
 
-	// delivery is not empty
-	if recipe.Delivery == "" {
-		log.Error().Msg("delivery is empty")
-		return false
+// readExtra reads the extra fields of a raw record with config.Extended
+func (r *JsonParser) readExtra(raw []byte) (*Extra, error) {
+	if !r.cfg.Extended {
+		return nil, nil
 	}
+	return readExtra(raw, r.recipeKeys)
+}
 
-	pattern := `^(\w+)\s+([1-9]|1[0-2])\s*(AM)\s*-\s*([1-9]|1[0-2])\s*(PM)$`
-	re := regexp.MustCompile(pattern)
-	matches := re.FindStringSubmatch(recipe.Delivery)
-	// Check if the format matches
-	if len(matches) != 6 {
-		log.Error().Str("delivery", recipe.Delivery).Msg("delivery format does not match")
-		return false
+// countRejected counts a rejected record for the metrics and the summary
+func (r *JsonParser) countRejected(reason string) {
+	r.rejected[reason]++
+	r.metrics.RecordRejected(reason)
+}
+
+// reject counts a record failing sanitization and logs it, sampled
+func (r *JsonParser) reject(reason string, recipe Recipe, msg string) {
+	r.countRejected(reason)
+	r.rejectLog.Warn().
+		Str("reason", reason).
+		Str("postcode", recipe.Postcode).
+		Str("delivery", recipe.Delivery).
+		Str("recipe", recipe.Recipe).
+		Msg(msg)
+}
+
+// logRejectedSummary logs the rejections of the run by reason, including the ones
+// dropped by sampling
+func (r *JsonParser) logRejectedSummary() {
+	if len(r.rejected) == 0 {
+		return
+	}
+	reasons := make([]string, 0, len(r.rejected))
+	total := 0
+	for reason, count := range r.rejected {
+		reasons = append(reasons, reason)
+		total += count
 	}
+	slices.Sort(reasons)
+	counts := zerolog.Dict()
+	for _, reason := range reasons {
+		counts.Int(reason, r.rejected[reason])
+	}
+	log.Warn().Int("rejected", total).Dict("reasons", counts).Msg("Rejected records")
+}
 
-	// check if recipe is not empty
-	if recipe.Recipe == "" {
-		log.Error().Msg("recipe is empty")
+// send adds the entry to the current batch and streams the batch once it is full.
+// It returns false instead of blocking once ctx is cancelled.
+func (r *JsonParser) send(ctx context.Context, entry Entry) bool {
+	if r.batch == nil {
+		r.batch = newBatch()
+	}
+	r.batch.Entries = append(r.batch.Entries, entry)
+	if len(r.batch.Entries) < BatchSize {
+		return true
+	}
+	return r.flush(ctx)
+}
+
+// flush streams the current batch, it returns false once ctx is cancelled
+func (r *JsonParser) flush(ctx context.Context) bool {
+	batch := r.batch
+	if batch == nil {
+		return true
+	}
+	r.batch = nil
+	if ctx.Err() != nil {
+		batch.Release()
 		return false
 	}
-	// check that recipe is less than 100 characters
-	if len(recipe.Recipe) > 100 {
-		log.Error().Msg("recipe is longer than 100 characters")
+	select {
+	case r.batches <- batch:
+		return true
+	case <-ctx.Done():
+		batch.Release()
 		return false
 	}
+}
+
+// checkRecipe normalizes the recipe, when enabled, checks it with the rules,
+// renames an alias to its canonical name and drops duplicates, when enabled
+func (r *JsonParser) checkRecipe(recipe Recipe) (Recipe, bool) {
+	if r.normalizer != nil {
+		var changes int
+		recipe, changes = r.normalizer.normalize(recipe)
+		if !r.sanitizeRecipe(recipe) {
+			return recipe, false
+		}
+		recipe, changes = r.normalizer.accept(recipe, changes)
+		r.normalized.add(changes)
+	} else if !r.sanitizeRecipe(recipe) {
+		return recipe, false
+	}
+	if canonical, ok := r.aliases[recipe.Recipe]; ok {
+		recipe.Alias, recipe.Recipe = recipe.Recipe, canonical
+	}
+	if r.deduper != nil {
+		r.deduped.Records++
+		if r.deduper.duplicate(recipe) {
+			r.deduped.Dropped++
+			r.reject(metrics.ReasonDuplicate, recipe, "duplicate record")
+			return recipe, false
+		}
+	}
+	return recipe, true
+}
 
+// Normalized returns the changes of the normalizer in the last Parse run
+func (r *JsonParser) Normalized() NormalizeReport {
+	return r.normalized
+}
+
+// Report returns the reports of the modes enabled in the last Parse run
+func (r *JsonParser) Report() Report {
+	var report Report
+	if r.normalizer != nil {
+		normalized := r.normalized
+		report.Normalized = &normalized
+	}
+	if r.deduper != nil {
+		deduped := r.Deduped()
+		report.Deduped = &deduped
+	}
+	return report
+}
+
+// Deduped returns the duplicates dropped in the last Parse run
+func (r *JsonParser) Deduped() DedupeReport {
+	if r.deduper == nil {
+		return r.deduped
+	}
+	return r.deduper.report(r.deduped)
+}
+
+// sanitizeRecipe checks the recipe with every rule, the first failing one rejects it
+func (r *JsonParser) sanitizeRecipe(recipe Recipe) bool {
+	for _, rule := range r.rules {
+		if msg := rule.Check(recipe); msg != "" {
+			r.reject(rule.ID(), recipe, msg)
+			return false
+		}
+	}
 	return true
 }
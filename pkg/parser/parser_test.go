@@ -1,12 +1,21 @@
 package parser
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"os"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 )
 
 func TestJsonParser_Parse(t *testing.T) {
@@ -51,22 +60,11 @@ func TestJsonParser_Parse(t *testing.T) {
 			parser := NewJsonParser(cfg)
 
 			// Start JSON stream parsing
-			go parser.Parse()
-
-			// Creating a channel to receive entries
-			entryCh := make(chan Entry, len(tt.expected))
-			go func() {
-				defer close(entryCh)
-				for entry := range parser.Stream() {
-					entryCh <- entry
-				}
-			}()
-
-			time.Sleep(time.Second)
+			go parser.Parse(context.Background())
 
-			// Collect entries from the channel
+			// Collect entries until Parse closes the stream
 			var actual []Entry
-			for entry := range entryCh {
+			for entry := range parser.Stream() {
 				// Check if error is expected
 				if entry.Error != nil && entry.Error.Error() == errInvalidTimeFormat.Error() {
 					continue
@@ -173,3 +171,252 @@ func TestJsonParser_SanitizeRecipe(t *testing.T) {
 		})
 	}
 }
+
+func TestJsonParser_ParseCancel(t *testing.T) {
+	file, err := createTempJSONFile(t, `[{"Postcode": "12345", "Delivery": "Monday 9AM - 5PM", "Recipe": "RecipeA"}, {"Postcode": "12345", "Delivery": "Monday 10AM - 6PM", "Recipe": "RecipeB"}]`)
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	parser := NewJsonParser(config.Config{File: file.Name()})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parser.Parse(ctx)
+	}()
+
+	// the consumer stops after the first entry, the producer must not block on the second
+	<-parser.Stream()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Parse to return after cancellation, but it is still running")
+	}
+	// an entry of the batch being unpacked may still arrive, then the stream closes
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for range parser.Stream() {
+		}
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Error("Expected the stream to be closed, but it is open")
+	}
+}
+
+func TestJsonParser_ParseReleasesParser(t *testing.T) {
+	file, err := createTempJSONFile(t, `[{"Postcode": "12345", "Delivery": "Monday 9AM - 5PM", "Recipe": "RecipeA"}]`)
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	// a context outliving the parser, like the one of a command run
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	released := make(chan struct{})
+	func() {
+		parser := NewJsonParser(config.Config{File: file.Name()})
+		runtime.SetFinalizer(parser, func(*JsonParser) { close(released) })
+		go parser.Parse(ctx)
+		for range parser.Stream() {
+		}
+		// a second call must not panic
+		parser.Parse(ctx)
+	}()
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		select {
+		case <-released:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Error("Expected the parser to be released once parsed, but ctx keeps it")
+}
+
+func TestJsonParser_Progress(t *testing.T) {
+	content := `[{"Postcode": "12345", "Delivery": "Monday 9AM - 5PM", "Recipe": "RecipeA"}, {"Postcode": "12345", "Delivery": "InvalidTimeFormat", "Recipe": "RecipeB"}]`
+	file, err := createTempJSONFile(t, content)
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	var reports []Progress
+	parser := NewJsonParser(config.Config{File: file.Name()}).WithProgress(func(p Progress) {
+		reports = append(reports, p)
+	}, 0)
+	go parser.Parse(context.Background())
+	for range parser.Stream() {
+	}
+
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 report, but got %v", reports)
+	}
+	got := reports[0]
+	expected := Progress{
+		File:       file.Name(),
+		BytesRead:  int64(len(content)),
+		TotalBytes: int64(len(content)),
+		Records:    2,
+		Rejected:   1,
+		Elapsed:    got.Elapsed,
+		Done:       true,
+	}
+	if got != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, got)
+	}
+	if got.ETA() != 0 {
+		t.Errorf("Expected no ETA once done, but got %v", got.ETA())
+	}
+}
+
+func TestJsonParser_Metrics(t *testing.T) {
+	content := `[{"Postcode": "12345", "Delivery": "Monday 9AM - 5PM", "Recipe": "RecipeA"}, {"Postcode": "", "Delivery": "Monday 9AM - 5PM", "Recipe": "RecipeB"}, {"Postcode": "12345", "Delivery": "InvalidTimeFormat", "Recipe": "RecipeC"}]`
+	file, err := createTempJSONFile(t, content)
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	registry := metrics.NewRegistry()
+	parser := NewJsonParser(config.Config{File: file.Name()}).WithMetrics(registry)
+	go parser.Parse(context.Background())
+	for range parser.Stream() {
+	}
+
+	got := registry.Snapshot()
+	if got.RecordsParsed != 1 {
+		t.Errorf("Expected %v, but got %v", 1, got.RecordsParsed)
+	}
+	expected := map[string]int64{metrics.ReasonEmptyPostcode: 1, metrics.ReasonInvalidDelivery: 1}
+	if !reflect.DeepEqual(got.RecordsRejected, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got.RecordsRejected)
+	}
+	if got.ParseDuration.Count != 1 {
+		t.Errorf("Expected %v, but got %v", 1, got.ParseDuration.Count)
+	}
+}
+
+func TestJsonParser_RejectionLogs(t *testing.T) {
+	records := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		records = append(records, `{"Postcode": "12345", "Delivery": "InvalidTimeFormat", "Recipe": "RecipeA"}`)
+	}
+	records = append(records, `{"Postcode": "", "Delivery": "Monday 9AM - 5PM", "Recipe": "RecipeB"}`)
+	file, err := createTempJSONFile(t, "["+strings.Join(records, ",")+"]")
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	var logs bytes.Buffer
+	defaultLogger := log.Logger
+	log.Logger = zerolog.New(&logs)
+	defer func() { log.Logger = defaultLogger }()
+
+	parser := NewJsonParser(config.Config{File: file.Name(), LogRejections: 3})
+	go parser.Parse(context.Background())
+	for range parser.Stream() {
+	}
+
+	var rejections int
+	var summary struct {
+		Rejected int            `json:"rejected"`
+		Reasons  map[string]int `json:"reasons"`
+	}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if strings.Contains(line, `"reason"`) {
+			rejections++
+			continue
+		}
+		if err := json.Unmarshal([]byte(line), &summary); err != nil {
+			t.Fatalf("Error decoding log line %q: %v", line, err)
+		}
+	}
+
+	if rejections != 3 {
+		t.Errorf("Expected %v logged rejections, but got %v", 3, rejections)
+	}
+	expectedReasons := map[string]int{metrics.ReasonInvalidDelivery: 100, metrics.ReasonEmptyPostcode: 1}
+	if summary.Rejected != 101 || !reflect.DeepEqual(summary.Reasons, expectedReasons) {
+		t.Errorf("Expected 101 rejections %v, but got %v %v", expectedReasons, summary.Rejected, summary.Reasons)
+	}
+}
+
+func TestJsonParser_Aliases(t *testing.T) {
+	content, err := os.ReadFile("../../cmd/stats/testdata/renamed.json")
+	if err != nil {
+		t.Fatalf("Error reading input: %v", err)
+	}
+	cfg := config.Config{Aliases: "../../cmd/stats/testdata/aliases.yaml"}
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		expected []Recipe
+	}{
+		{
+			name: "exact names",
+			cfg:  cfg,
+			expected: []Recipe{
+				{Recipe: "Speedy Steak Fajitas"},
+				{Recipe: "Speedy Steak Fajitas", Alias: "Speedy Steak Fajitas 2.0"},
+				{Recipe: "Speedy Steak Fajitas", Alias: "Speedy Steak Fajitas 2.0"},
+				{Recipe: "Speedy Steak Fajitas", Alias: "Speedy Fajitas"},
+				{Recipe: "Creamy Dill Chicken", Alias: "Dill Chicken"},
+				{Recipe: "Tex-Mex Tilapia"},
+				{Recipe: "speedy fajitas"},
+			},
+		},
+		{
+			// the names of the alias file are the display names of their variants
+			name: "normalized names",
+			cfg:  cfg.WithNormalize(true),
+			expected: []Recipe{
+				{Recipe: "Speedy Steak Fajitas"},
+				{Recipe: "Speedy Steak Fajitas", Alias: "Speedy Steak Fajitas 2.0"},
+				{Recipe: "Speedy Steak Fajitas", Alias: "Speedy Steak Fajitas 2.0"},
+				{Recipe: "Speedy Steak Fajitas", Alias: "Speedy Fajitas"},
+				{Recipe: "Creamy Dill Chicken", Alias: "Dill Chicken"},
+				{Recipe: "Tex-Mex Tilapia"},
+				{Recipe: "Speedy Steak Fajitas", Alias: "Speedy Fajitas"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			for _, engine := range []string{"json", "fast"} {
+				_, entries := parseWith(t, content, tt.cfg.WithEngine(engine))
+				var got []Recipe
+				for _, entry := range entries {
+					if entry.Error != nil {
+						t.Fatalf("Expected no error with the %s engine, but got %v", engine, entry.Error)
+					}
+					got = append(got, Recipe{Recipe: entry.Recipe.Recipe, Alias: entry.Recipe.Alias})
+				}
+				if !reflect.DeepEqual(got, tt.expected) {
+					t.Errorf("Expected %+v with the %s engine, but got %+v", tt.expected, engine, got)
+				}
+			}
+		})
+	}
+}
+
+func TestJsonParser_AliasesError(t *testing.T) {
+	_, entries := parseWith(t, []byte(`[`+validRecord+`]`), config.Config{Aliases: "./testdata/missing.yaml"})
+	var inputErr *InputError
+	if len(entries) != 1 || !errors.As(entries[0].Error, &inputErr) {
+		t.Errorf("Expected an input error, but got %+v", entries)
+	}
+}
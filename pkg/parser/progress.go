@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// progressCheckEvery is the number of records between two clock reads, so progress
+// tracking stays cheap on millions of records
+const progressCheckEvery = 256
+
+// Progress is a snapshot of a running Parse, see JsonParser.WithProgress
+type Progress struct {
+	// File is the input file being read
+	File string
+	// BytesRead and TotalBytes cover every input file of the run
+	BytesRead  int64
+	TotalBytes int64
+	// Records counts the decoded records, including the Rejected ones
+	Records  int64
+	Rejected int64
+	Elapsed  time.Duration
+	// Done is set on the last report of the run
+	Done bool
+}
+
+// Rate returns the records read per second
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Records) / p.Elapsed.Seconds()
+}
+
+// ETA estimates the remaining time from the bytes read so far
+func (p Progress) ETA() time.Duration {
+	if p.BytesRead <= 0 || p.BytesRead >= p.TotalBytes {
+		return 0
+	}
+	remaining := float64(p.TotalBytes-p.BytesRead) / float64(p.BytesRead)
+	return time.Duration(remaining * float64(p.Elapsed))
+}
+
+// ProgressFunc receives the progress of a Parse. It is called from the parsing
+// goroutine, so it should return quickly.
+type ProgressFunc func(Progress)
+
+// countingReader counts the bytes read from the input file
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// progressTracker reports the progress of one Parse run. A nil tracker ignores
+// every call, which is the case without a ProgressFunc.
+type progressTracker struct {
+	fn       ProgressFunc
+	interval time.Duration
+	start    time.Time
+	last     time.Time
+	// done is the size of the files already read, position returns the offset
+	// in the current file
+	done     int64
+	position func() int64
+	progress Progress
+}
+
+func newProgressTracker(fn ProgressFunc, interval time.Duration, files []string) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	t := &progressTracker{fn: fn, interval: interval, start: time.Now()}
+	t.last = t.start
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			t.progress.TotalBytes += info.Size()
+		}
+	}
+	return t
+}
+
+// wrap counts the bytes read from the file
+func (t *progressTracker) wrap(fileName string, r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	reader := &countingReader{r: r}
+	t.startFile(fileName, func() int64 { return reader.n })
+	return reader
+}
+
+// startFile tracks a new file, position returns the bytes read from it so far
+func (t *progressTracker) startFile(fileName string, position func() int64) {
+	if t == nil {
+		return
+	}
+	t.finishFile()
+	t.progress.File = fileName
+	t.position = position
+}
+
+// finishFile adds the bytes of the current file to the finished ones
+func (t *progressTracker) finishFile() {
+	if t.position != nil {
+		t.done += t.position()
+		t.position = nil
+	}
+}
+
+// record counts a decoded record and reports once the interval has passed
+func (t *progressTracker) record(rejected bool) {
+	if t == nil {
+		return
+	}
+	t.progress.Records++
+	if rejected {
+		t.progress.Rejected++
+	}
+	if t.progress.Records%progressCheckEvery != 0 {
+		return
+	}
+	if now := time.Now(); now.Sub(t.last) >= t.interval {
+		t.last = now
+		t.report(false)
+	}
+}
+
+// report calls the ProgressFunc with the current progress
+func (t *progressTracker) report(done bool) {
+	if t == nil {
+		return
+	}
+	if done {
+		t.finishFile()
+	}
+	t.progress.BytesRead = t.done
+	if t.position != nil {
+		t.progress.BytesRead += t.position()
+	}
+	t.progress.Elapsed = time.Since(t.start)
+	t.progress.Done = done
+	t.fn(t.progress)
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/config"
+)
+
+// Rule is one check of the sanitization. A record failing it is rejected and
+// logged with the rule ID as the reason.
+type Rule interface {
+	// ID names the rule in logs and metrics
+	ID() string
+	// Check returns why recipe fails the rule, or "" when it passes
+	Check(recipe Recipe) string
+}
+
+// ruleFunc is a Rule checked by a function
+type ruleFunc struct {
+	id    string
+	check func(recipe Recipe) string
+}
+
+func (r ruleFunc) ID() string {
+	return r.id
+}
+
+func (r ruleFunc) Check(recipe Recipe) string {
+	return r.check(recipe)
+}
+
+// NewRules compiles the rules of cfg once, in the order records are checked: the
+// postcode, delivery and recipe rules, then the custom rules. A zero limit or
+// empty format keeps the one of config.DefaultRules.
+func NewRules(cfg config.Rules) ([]Rule, error) {
+	defaults := config.DefaultRules()
+	maxPostcode := cmpOr(cfg.MaxPostcodeLength, defaults.MaxPostcodeLength)
+	maxRecipe := cmpOr(cfg.MaxRecipeLength, defaults.MaxRecipeLength)
+	hours := cmpOr(cfg.DeliveryHours, defaults.DeliveryHours)
+	// the messages are built once, not per record
+	longPostcode := fmt.Sprintf("postcode is longer than %d characters", maxPostcode)
+	longRecipe := fmt.Sprintf("recipe is longer than %d characters", maxRecipe)
+	postcodeMismatch := "postcode does not match " + cfg.PostcodePattern
+
+	rules := []Rule{
+		ruleFunc{config.RuleEmptyPostcode, func(r Recipe) string {
+			return failIf(r.Postcode == "", "postcode is empty")
+		}},
+		ruleFunc{config.RuleLongPostcode, func(r Recipe) string {
+			return failIf(len(r.Postcode) > maxPostcode, longPostcode)
+		}},
+	}
+	if cfg.PostcodePattern != "" {
+		pattern, err := regexp.Compile(cfg.PostcodePattern)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid postcode pattern")
+		}
+		rules = append(rules, ruleFunc{config.RulePostcodePattern, func(r Recipe) string {
+			return failIf(!pattern.MatchString(r.Postcode), postcodeMismatch)
+		}})
+	}
+
+	rules = append(rules,
+		ruleFunc{config.RuleEmptyDelivery, func(r Recipe) string {
+			return failIf(r.Delivery == "", "delivery is empty")
+		}},
+		ruleFunc{config.RuleInvalidDelivery, func(r Recipe) string {
+			if hours == config.DeliveryAMPM {
+				return failIf(!amPmPattern.MatchString(r.Delivery), "delivery format does not match")
+			}
+			_, start, end, err := ParseDelivery(r.Delivery)
+			if err != nil {
+				return "delivery format does not match"
+			}
+			return failIf(start >= end, "delivery ends before it starts")
+		}},
+	)
+	if len(cfg.Weekdays) > 0 {
+		weekdays := slices.Clone(cfg.Weekdays)
+		rules = append(rules, ruleFunc{config.RuleInvalidWeekday, func(r Recipe) string {
+			day, _, _, _ := ParseDelivery(r.Delivery)
+			allowed := slices.ContainsFunc(weekdays, func(w string) bool { return strings.EqualFold(w, day) })
+			return failIf(!allowed, "delivery day is not one of "+strings.Join(weekdays, ", "))
+		}})
+	}
+
+	rules = append(rules,
+		ruleFunc{config.RuleEmptyRecipe, func(r Recipe) string {
+			return failIf(r.Recipe == "", "recipe is empty")
+		}},
+		ruleFunc{config.RuleLongRecipe, func(r Recipe) string {
+			return failIf(len(r.Recipe) > maxRecipe, longRecipe)
+		}},
+	)
+
+	for _, custom := range cfg.Custom {
+		rule, err := newCustomRule(custom)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// newCustomRule matches one recipe field against the pattern of a custom rule
+func newCustomRule(custom config.CustomRule) (Rule, error) {
+	pattern, err := regexp.Compile(custom.Pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid pattern of rule %s", custom.ID)
+	}
+	var field func(r Recipe) string
+	switch custom.Field {
+	case "recipe":
+		field = func(r Recipe) string { return r.Recipe }
+	case "postcode":
+		field = func(r Recipe) string { return r.Postcode }
+	case "delivery":
+		field = func(r Recipe) string { return r.Delivery }
+	default:
+		return nil, errors.Errorf("unknown field %q of rule %s", custom.Field, custom.ID)
+	}
+
+	msg := custom.Field + " does not match " + custom.Pattern
+	if custom.Reject {
+		msg = custom.Field + " matches " + custom.Pattern
+	}
+	return ruleFunc{custom.ID, func(r Recipe) string {
+		return failIf(pattern.MatchString(field(r)) == custom.Reject, msg)
+	}}, nil
+}
+
+// failIf returns msg when the rule failed
+func failIf(failed bool, msg string) string {
+	if failed {
+		return msg
+	}
+	return ""
+}
+
+// cmpOr returns the first value that is not zero, like cmp.Or of go1.22
+func cmpOr[T comparable](values ...T) T {
+	var zero T
+	for _, v := range values {
+		if v != zero {
+			return v
+		}
+	}
+	return zero
+}
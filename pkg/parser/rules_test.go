@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
+)
+
+func TestNewRules(t *testing.T) {
+	valid := Recipe{Postcode: "10224", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 1AM - 7PM"}
+	tests := []struct {
+		name     string
+		rules    config.Rules
+		recipe   Recipe
+		expected string
+	}{
+		{name: "zero rules keep the defaults", recipe: valid},
+		{name: "default postcode length", recipe: Recipe{Postcode: "12345678901", Recipe: "A", Delivery: valid.Delivery}, expected: metrics.ReasonLongPostcode},
+		{name: "shorter postcodes", rules: config.Rules{MaxPostcodeLength: 4}, recipe: valid, expected: metrics.ReasonLongPostcode},
+		{name: "longer recipes", rules: config.Rules{MaxRecipeLength: 200}, recipe: Recipe{Postcode: "10224", Recipe: string(make([]byte, 150)), Delivery: valid.Delivery}},
+		{name: "postcode pattern", rules: config.Rules{PostcodePattern: `^\d{5}$`}, recipe: Recipe{Postcode: "A1 2B", Recipe: "A", Delivery: valid.Delivery}, expected: metrics.ReasonPostcodePattern},
+		{name: "allowed weekday", rules: config.Rules{Weekdays: []string{"monday", "wednesday"}}, recipe: valid},
+		{name: "other weekday", rules: config.Rules{Weekdays: []string{"Monday"}}, recipe: valid, expected: metrics.ReasonInvalidWeekday},
+		{name: "PM window by default", recipe: Recipe{Postcode: "10224", Recipe: "A", Delivery: "Monday 2PM - 8PM"}, expected: metrics.ReasonInvalidDelivery},
+		{name: "PM window with any hours", rules: config.Rules{DeliveryHours: config.DeliveryAny}, recipe: Recipe{Postcode: "10224", Recipe: "A", Delivery: "Monday 2PM - 8PM"}},
+		{name: "window ending before its start", rules: config.Rules{DeliveryHours: config.DeliveryAny}, recipe: Recipe{Postcode: "10224", Recipe: "A", Delivery: "Monday 8PM - 2PM"}, expected: metrics.ReasonInvalidDelivery},
+		{
+			name:     "custom rule must match",
+			rules:    config.Rules{Custom: []config.CustomRule{{ID: "berlin", Field: "postcode", Pattern: `^10`}}},
+			recipe:   Recipe{Postcode: "20224", Recipe: "A", Delivery: valid.Delivery},
+			expected: "berlin",
+		},
+		{
+			name:     "custom rule rejecting matches",
+			rules:    config.Rules{Custom: []config.CustomRule{{ID: "no_test", Field: "recipe", Pattern: `(?i)\btest\b`, Reject: true}}},
+			recipe:   Recipe{Postcode: "10224", Recipe: "Test Recipe", Delivery: valid.Delivery},
+			expected: "no_test",
+		},
+		{
+			name:     "built-in rules come first",
+			rules:    config.Rules{Custom: []config.CustomRule{{ID: "berlin", Field: "postcode", Pattern: `^10`}}},
+			recipe:   Recipe{Recipe: "A", Delivery: valid.Delivery},
+			expected: metrics.ReasonEmptyPostcode,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := NewRules(tt.rules)
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			got := ""
+			for _, rule := range rules {
+				if rule.Check(tt.recipe) != "" {
+					got = rule.ID()
+					break
+				}
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, but got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewRules_Invalid(t *testing.T) {
+	for _, rules := range []config.Rules{
+		{PostcodePattern: "("},
+		{Custom: []config.CustomRule{{ID: "x", Field: "zip", Pattern: "x"}}},
+	} {
+		if _, err := NewRules(rules); err == nil {
+			t.Errorf("Expected an error for %+v, but got none", rules)
+		}
+	}
+}
+
+// noVowels is a Rule of a library user
+type noVowels struct{}
+
+func (noVowels) ID() string { return "no_vowels" }
+
+func (noVowels) Check(recipe Recipe) string {
+	return failIf(recipe.Recipe == "Dll", "recipe has no vowels")
+}
+
+func TestJsonParser_WithRules(t *testing.T) {
+	content := `[` + validRecord + `, {"postcode": "10224", "recipe": "Dll", "delivery": "Wednesday 1AM - 7PM"}]`
+	file, err := createTempJSONFile(t, content)
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	registry := metrics.NewRegistry()
+	p := NewJsonParser(config.Config{File: file.Name()}).WithRules(noVowels{}).WithMetrics(registry)
+	go p.Parse(context.Background())
+	var recipes []Recipe
+	for entry := range p.Stream() {
+		recipes = append(recipes, entry.Recipe)
+	}
+
+	if len(recipes) != 1 || recipes[0].Recipe != "Creamy Dill Chicken" {
+		t.Errorf("Expected %v, but got %v", "Creamy Dill Chicken", recipes)
+	}
+	expected := map[string]int64{"no_vowels": 1}
+	if got := registry.Snapshot().RecordsRejected; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
@@ -1,5 +1,10 @@
 package parser
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // Entry is a struct that is used to stream data over the channel
 type Entry struct {
 	Recipe Recipe `json:"recipe"`
@@ -10,4 +15,53 @@ type Recipe struct {
 	Recipe   string `json:"recipe"`
 	Postcode string `json:"postcode"`
 	Delivery string `json:"delivery"`
+	// Alias is the name of the record when Recipe is its canonical name, see
+	// config.Aliases
+	Alias string `json:"-"`
+	// Extra holds the optional fields of the record, it is only read with
+	// config.Extended and nil otherwise
+	Extra *Extra `json:"-"`
+}
+
+// Extra is the rest of a record besides the recipe fields. The fields known from
+// the exports are typed, nil or empty when the record does not have them, any other
+// key is kept raw in Fields.
+type Extra struct {
+	CustomerID string                     `json:"customer_id,omitempty"`
+	BoxSize    *int                       `json:"box_size,omitempty"`
+	OrderDate  string                     `json:"order_date,omitempty"`
+	Price      *float64                   `json:"price,omitempty"`
+	Fields     map[string]json.RawMessage `json:"fields,omitempty"`
+}
+
+// InputError is streamed when an input file cannot be read at all, e.g. it does not
+// exist or is not a JSON array, as opposed to a single malformed recipe
+type InputError struct {
+	File string
+	Err  error
+}
+
+func (e *InputError) Error() string {
+	return e.File + ": " + e.Err.Error()
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is streamed for a recipe that cannot be decoded. Offset is the byte
+// offset in File where reading the recipe started. After a syntax error the rest of
+// the file cannot be split into recipes, so it is the last entry of the file.
+type DecodeError struct {
+	File   string
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: offset %d: %v", e.File, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
 }
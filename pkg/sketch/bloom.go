@@ -0,0 +1,130 @@
+package sketch
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Bloom is a Bloom filter. Add never misses an item added before, and reports an
+// item that was not added as present with probability FalsePositiveRate.
+type Bloom struct {
+	bits   []uint64
+	size   uint64
+	hashes int
+	added  int
+}
+
+// NewBloom creates a filter of -capacity × ln(rate) / ln(2)² bits and
+// size/capacity × ln(2) hashes, so it holds capacity items at the false-positive
+// rate. More items fill it and raise the rate, see FalsePositiveRate.
+func NewBloom(capacity int, rate float64) (*Bloom, error) {
+	if capacity < 1 {
+		return nil, errors.New("capacity must be at least 1")
+	}
+	if rate <= 0 || rate >= 1 {
+		return nil, errors.New("false-positive rate must be between 0 and 1")
+	}
+
+	size := uint64(math.Ceil(-float64(capacity) * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	size = (size + 63) &^ 63
+	hashes := max(1, int(math.Round(float64(size)/float64(capacity)*math.Ln2)))
+	return &Bloom{
+		bits:   make([]uint64, size/64),
+		size:   size,
+		hashes: hashes,
+	}, nil
+}
+
+// Add adds s and reports whether it was present already
+func (b *Bloom) Add(s string) bool {
+	return b.AddHash(Hash64(s))
+}
+
+// AddHash adds an item by its Hash64 and reports whether it was present already
+func (b *Bloom) AddHash(h uint64) bool {
+	h1, h2 := h&0xffffffff, h>>32|1
+	present := true
+	for i := 0; i < b.hashes; i++ {
+		idx := (h1 + uint64(i)*h2) % b.size
+		word, mask := idx/64, uint64(1)<<(idx%64)
+		if b.bits[word]&mask == 0 {
+			present = false
+			b.bits[word] |= mask
+		}
+	}
+	if !present {
+		b.added++
+	}
+	return present
+}
+
+// Contains reports whether s may have been added, without adding it
+func (b *Bloom) Contains(s string) bool {
+	h := Hash64(s)
+	h1, h2 := h&0xffffffff, h>>32|1
+	for i := 0; i < b.hashes; i++ {
+		idx := (h1 + uint64(i)*h2) % b.size
+		if b.bits[idx/64]&(uint64(1)<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Added returns the number of items added that were not present already
+func (b *Bloom) Added() int {
+	return b.added
+}
+
+// Bytes returns the memory used by the bits of the filter
+func (b *Bloom) Bytes() int {
+	return len(b.bits) * 8
+}
+
+// FalsePositiveRate estimates the probability that an item not added is reported
+// as present, (1 - e^(-hashes × added / size))^hashes. It stays below the rate of
+// NewBloom up to capacity items.
+func (b *Bloom) FalsePositiveRate() float64 {
+	return math.Pow(1-math.Exp(-float64(b.hashes)*float64(b.added)/float64(b.size)), float64(b.hashes))
+}
+
+// bloomHeader is the size of the size, hashes and added fields of MarshalBinary
+const bloomHeader = 3 * 8
+
+// MarshalBinary encodes the filter, to be restored by UnmarshalBinary
+func (b *Bloom) MarshalBinary() ([]byte, error) {
+	data := make([]byte, bloomHeader, bloomHeader+len(b.bits)*8)
+	binary.LittleEndian.PutUint64(data, b.size)
+	binary.LittleEndian.PutUint64(data[8:], uint64(b.hashes))
+	binary.LittleEndian.PutUint64(data[16:], uint64(b.added))
+	for _, word := range b.bits {
+		data = binary.LittleEndian.AppendUint64(data, word)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary restores a filter encoded by MarshalBinary. A filter created by
+// NewBloom only takes the items of a filter of the same size and hashes.
+func (b *Bloom) UnmarshalBinary(data []byte) error {
+	if len(data) < bloomHeader {
+		return errors.New("filter data is too short")
+	}
+	size := binary.LittleEndian.Uint64(data)
+	hashes := int(binary.LittleEndian.Uint64(data[8:]))
+	added := int(binary.LittleEndian.Uint64(data[16:]))
+	if size == 0 || size%64 != 0 || hashes < 1 || uint64(len(data)-bloomHeader) != size/8 {
+		return errors.New("invalid filter data")
+	}
+	if b.size != 0 && (b.size != size || b.hashes != hashes) {
+		return errors.Errorf("filter of %d bits and %d hashes does not match one of %d bits and %d hashes", size, hashes, b.size, b.hashes)
+	}
+
+	bits := make([]uint64, size/64)
+	for i := range bits {
+		bits[i] = binary.LittleEndian.Uint64(data[bloomHeader+i*8:])
+	}
+	b.bits, b.size, b.hashes, b.added = bits, size, hashes, added
+	return nil
+}
@@ -0,0 +1,85 @@
+package sketch
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// CountMin is a Count-Min sketch. An estimate never undercounts, and overcounts by
+// at most Epsilon × Total with probability 1 - Delta.
+type CountMin struct {
+	width   uint64
+	depth   int
+	counts  []uint32
+	total   int
+	epsilon float64
+	delta   float64
+}
+
+// NewCountMin creates a sketch of width ceil(e/epsilon) and depth ceil(ln(1/delta))
+func NewCountMin(epsilon, delta float64) (*CountMin, error) {
+	if epsilon <= 0 || epsilon >= 1 {
+		return nil, errors.New("epsilon must be between 0 and 1")
+	}
+	if delta <= 0 || delta >= 1 {
+		return nil, errors.New("delta must be between 0 and 1")
+	}
+
+	width := uint64(math.Ceil(math.E / epsilon))
+	depth := int(math.Ceil(math.Log(1 / delta)))
+	return &CountMin{
+		width:   width,
+		depth:   depth,
+		counts:  make([]uint32, width*uint64(depth)),
+		epsilon: epsilon,
+		delta:   delta,
+	}, nil
+}
+
+// Add counts s once and returns its new estimate
+func (c *CountMin) Add(s string) int {
+	c.total++
+	estimate := uint32(math.MaxUint32)
+	h1, h2 := c.hashes(s)
+	for i := 0; i < c.depth; i++ {
+		idx := uint64(i)*c.width + (h1+uint64(i)*h2)%c.width
+		if c.counts[idx] < math.MaxUint32 {
+			c.counts[idx]++
+		}
+		estimate = min(estimate, c.counts[idx])
+	}
+	return int(estimate)
+}
+
+// Estimate returns the estimated count of s
+func (c *CountMin) Estimate(s string) int {
+	estimate := uint32(math.MaxUint32)
+	h1, h2 := c.hashes(s)
+	for i := 0; i < c.depth; i++ {
+		estimate = min(estimate, c.counts[uint64(i)*c.width+(h1+uint64(i)*h2)%c.width])
+	}
+	return int(estimate)
+}
+
+// Total returns the number of items added
+func (c *CountMin) Total() int {
+	return c.total
+}
+
+// ErrorBound returns the maximum overcount, Epsilon × Total, that holds with
+// probability Confidence
+func (c *CountMin) ErrorBound() int {
+	return int(math.Ceil(c.epsilon * float64(c.total)))
+}
+
+// Confidence returns the probability 1 - Delta that an estimate is within ErrorBound
+func (c *CountMin) Confidence() float64 {
+	return 1 - c.delta
+}
+
+// hashes derives the row hashes from one 64-bit hash (Kirsch-Mitzenmacher)
+func (c *CountMin) hashes(s string) (uint64, uint64) {
+	h := Hash64(s)
+	return h & 0xffffffff, h>>32 | 1
+}
@@ -0,0 +1,45 @@
+// Package sketch contains probabilistic data structures used to aggregate recipe
+// data in bounded memory, trading exact counts for estimates with known error bounds.
+package sketch
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// Hash64 hashes s with FNV-1a, followed by the splitmix64 finalizer so the high
+// bits (used by HyperLogLog for register selection) are well distributed.
+// It is deterministic across runs, which keeps sketches reproducible.
+func Hash64(s string) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return mix64(h)
+}
+
+// HashFields hashes several strings like Hash64 hashes one, each followed by its
+// length so moving bytes from one field to the next changes the hash
+func HashFields(fields ...string) uint64 {
+	h := uint64(fnvOffset64)
+	for _, s := range fields {
+		for i := 0; i < len(s); i++ {
+			h ^= uint64(s[i])
+			h *= fnvPrime64
+		}
+		h ^= uint64(len(s))
+		h *= fnvPrime64
+	}
+	return mix64(h)
+}
+
+// mix64 is the splitmix64 finalizer
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
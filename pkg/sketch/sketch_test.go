@@ -0,0 +1,192 @@
+package sketch
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestHyperLogLog_Count(t *testing.T) {
+	tests := []struct {
+		name     string
+		distinct int
+	}{
+		{name: "few items", distinct: 100},
+		{name: "many items", distinct: 100_000},
+	}
+
+	for _, tt := range tests {
+		// avoid closure
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			hll, err := NewHyperLogLog(14)
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			// every item is added twice, duplicates must not be counted
+			for i := 0; i < 2*tt.distinct; i++ {
+				hll.Add(fmt.Sprintf("postcode-%d", i%tt.distinct))
+			}
+
+			got := hll.Count()
+			// 4 standard errors make the test practically deterministic
+			bound := 4 * hll.RelativeError() * float64(tt.distinct)
+			if math.Abs(float64(got-tt.distinct)) > bound {
+				t.Errorf("Expected %d ± %.0f, but got %d", tt.distinct, bound, got)
+			}
+		})
+	}
+}
+
+func TestNewHyperLogLog_InvalidPrecision(t *testing.T) {
+	for _, precision := range []int{MinPrecision - 1, MaxPrecision + 1} {
+		if _, err := NewHyperLogLog(precision); err == nil {
+			t.Errorf("Expected error for precision %d", precision)
+		}
+	}
+}
+
+func TestCountMin_Estimate(t *testing.T) {
+	cms, err := NewCountMin(0.001, 0.01)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	exact := make(map[string]int)
+	for i := 0; i < 50_000; i++ {
+		key := fmt.Sprintf("recipe-%d", i%(1+i%500))
+		exact[key]++
+		cms.Add(key)
+	}
+
+	if cms.Total() != 50_000 {
+		t.Errorf("Expected total 50000, but got %d", cms.Total())
+	}
+	for key, count := range exact {
+		got := cms.Estimate(key)
+		if got < count {
+			t.Fatalf("%s: estimate %d is lower than the exact count %d", key, got, count)
+		}
+		if got > count+cms.ErrorBound() {
+			t.Errorf("%s: estimate %d exceeds %d + %d", key, got, count, cms.ErrorBound())
+		}
+	}
+}
+
+func TestTopK_Items(t *testing.T) {
+	cms, err := NewCountMin(0.001, 0.01)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	topK := NewTopK(3, cms)
+	// key-i is added i times, interleaved
+	for round := 0; round < 20; round++ {
+		for i := 1; i <= 20; i++ {
+			if round < i {
+				topK.Add(fmt.Sprintf("key-%02d", i))
+			}
+		}
+	}
+
+	expected := []Item{{Key: "key-20", Count: 20}, {Key: "key-19", Count: 19}, {Key: "key-18", Count: 18}}
+	got := topK.Items()
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+func TestBloom_Add(t *testing.T) {
+	const capacity, rate = 50_000, 0.01
+	bloom, err := NewBloom(capacity, rate)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	for i := 0; i < capacity; i++ {
+		bloom.Add(fmt.Sprintf("record-%d", i))
+	}
+
+	// an added item is always present
+	for i := 0; i < capacity; i++ {
+		if !bloom.Add(fmt.Sprintf("record-%d", i)) {
+			t.Fatalf("Expected record-%d to be present", i)
+		}
+	}
+	if got := bloom.FalsePositiveRate(); got > rate*1.1 {
+		t.Errorf("Expected a false-positive rate of at most %v, but got %v", rate*1.1, got)
+	}
+
+	// items not added are reported present at about the false-positive rate
+	falsePositives := 0
+	for i := 0; i < capacity; i++ {
+		if bloom.Contains(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if got := float64(falsePositives) / capacity; got > 2*rate {
+		t.Errorf("Expected a false-positive rate of at most %v, but got %v", 2*rate, got)
+	}
+}
+
+func TestNewBloom_Invalid(t *testing.T) {
+	tests := []struct {
+		capacity int
+		rate     float64
+	}{
+		{capacity: 0, rate: 0.01},
+		{capacity: 100, rate: 0},
+		{capacity: 100, rate: 1},
+	}
+	for _, tt := range tests {
+		if _, err := NewBloom(tt.capacity, tt.rate); err == nil {
+			t.Errorf("Expected error for capacity %d and rate %v", tt.capacity, tt.rate)
+		}
+	}
+}
+
+func TestBloom_MarshalBinary(t *testing.T) {
+	b, err := NewBloom(1000, 0.01)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		b.Add(fmt.Sprint(i))
+	}
+	data, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	restored, err := NewBloom(1000, 0.01)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if !reflect.DeepEqual(restored, b) {
+		t.Errorf("Expected %+v, but got %+v", b, restored)
+	}
+	if !restored.Add("42") {
+		t.Errorf("Expected an item of the encoded filter to be present")
+	}
+
+	other, err := NewBloom(2000, 0.01)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if err := other.UnmarshalBinary(data); err == nil {
+		t.Errorf("Expected an error for a filter of another size")
+	}
+	if err := new(Bloom).UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Errorf("Expected an error for truncated data")
+	}
+}
+
+func TestHashFields(t *testing.T) {
+	if HashFields("ab", "c") == HashFields("a", "bc") {
+		t.Errorf("Expected different hashes when bytes move between fields")
+	}
+	if HashFields("ab", "c") != HashFields("ab", "c") {
+		t.Errorf("Expected the same hash for the same fields")
+	}
+}
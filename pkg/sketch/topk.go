@@ -0,0 +1,109 @@
+package sketch
+
+import (
+	"cmp"
+	"container/heap"
+	"slices"
+)
+
+// TopK tracks the k heavy hitters of a stream using the estimates of a CountMin
+// sketch: a key enters the min-heap once its estimate exceeds the smallest one kept.
+type TopK struct {
+	k      int
+	sketch *CountMin
+	heap   itemHeap
+	index  map[string]*item
+}
+
+// Item is a heavy hitter with its estimated count
+type Item struct {
+	Key   string
+	Count int
+}
+
+type item struct {
+	Item
+	pos int
+}
+
+func NewTopK(k int, sketch *CountMin) *TopK {
+	return &TopK{
+		k:      k,
+		sketch: sketch,
+		index:  make(map[string]*item, k),
+	}
+}
+
+// Add counts s in the sketch and updates the heavy hitters
+func (t *TopK) Add(s string) {
+	estimate := t.sketch.Add(s)
+	if it, ok := t.index[s]; ok {
+		it.Count = estimate
+		heap.Fix(&t.heap, it.pos)
+		return
+	}
+	if len(t.heap) < t.k {
+		it := &item{Item: Item{Key: s, Count: estimate}}
+		heap.Push(&t.heap, it)
+		t.index[s] = it
+		return
+	}
+	if t.k > 0 && estimate > t.heap[0].Count {
+		evicted := t.heap[0]
+		delete(t.index, evicted.Key)
+		evicted.Item = Item{Key: s, Count: estimate}
+		heap.Fix(&t.heap, 0)
+		t.index[s] = evicted
+	}
+}
+
+// Items returns the heavy hitters ordered by count (descending), then key
+func (t *TopK) Items() []Item {
+	items := make([]Item, 0, len(t.heap))
+	for _, it := range t.heap {
+		items = append(items, it.Item)
+	}
+	slices.SortFunc(items, func(a, b Item) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+	return items
+}
+
+// Sketch returns the underlying CountMin sketch
+func (t *TopK) Sketch() *CountMin {
+	return t.sketch
+}
+
+type itemHeap []*item
+
+func (h itemHeap) Len() int { return len(h) }
+
+func (h itemHeap) Less(i, j int) bool {
+	if h[i].Count != h[j].Count {
+		return h[i].Count < h[j].Count
+	}
+	// on equal counts the alphabetically last key is evicted first
+	return h[i].Key > h[j].Key
+}
+
+func (h itemHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *itemHeap) Push(x any) {
+	it := x.(*item)
+	it.pos = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *itemHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
@@ -0,0 +1,34 @@
+package stats
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+// addAlias counts a record read under an alias of its canonical recipe name
+func (st *State) addAlias(recipe parser.Recipe) {
+	counts := st.AliasCounts[recipe.Recipe]
+	if counts == nil {
+		counts = make(map[string]int)
+		st.AliasCounts[recipe.Recipe] = counts
+	}
+	counts[recipe.Alias]++
+}
+
+// aliasCounts returns the aliases counted under a recipe, sorted by alias, or nil
+func (st *State) aliasCounts(recipe string) []AliasCount {
+	counts := st.AliasCounts[recipe]
+	if len(counts) == 0 {
+		return nil
+	}
+	result := make([]AliasCount, 0, len(counts))
+	for alias, count := range counts {
+		result = append(result, AliasCount{Alias: alias, Count: count})
+	}
+	slices.SortFunc(result, func(a, b AliasCount) int {
+		return cmp.Compare(a.Alias, b.Alias)
+	})
+	return result
+}
@@ -0,0 +1,64 @@
+package stats
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+func TestJsonStats_Aliases(t *testing.T) {
+	cfg := config.Default().
+		WithFile("../../cmd/stats/testdata/renamed.json").
+		WithAliases("../../cmd/stats/testdata/aliases.yaml").
+		WithShowAliases(true).
+		WithWords([]string{"Fajitas"}).
+		WithLogRejections(0)
+	expected := []RecipeCount{
+		{Recipe: "Creamy Dill Chicken", Count: 1, Aliases: []AliasCount{{Alias: "Dill Chicken", Count: 1}}},
+		{Recipe: "Speedy Steak Fajitas", Count: 4, Aliases: []AliasCount{{Alias: "Speedy Fajitas", Count: 1}, {Alias: "Speedy Steak Fajitas 2.0", Count: 2}}},
+		{Recipe: "Tex-Mex Tilapia", Count: 1},
+		{Recipe: "speedy fajitas", Count: 1},
+	}
+	expectedMatches := []string{"Speedy Steak Fajitas", "speedy fajitas"}
+
+	for _, engine := range []string{"json", "fast"} {
+		cfg := cfg.WithEngine(engine)
+		p := parser.NewJsonParser(cfg)
+		go p.Parse(context.Background())
+		got, err := NewJsonStats(p, cfg).Generate(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(got.CountPerRecipe, expected) || !reflect.DeepEqual(got.MatchByName, expectedMatches) {
+			t.Errorf("Expected %+v and %v, but got %+v and %v with the %s engine", expected, expectedMatches, got.CountPerRecipe, got.MatchByName, engine)
+		}
+	}
+
+	// the alias counts survive a saved state, and are only listed with ShowAliases
+	st := NewState(cfg)
+	p := parser.NewJsonParser(cfg)
+	go p.Parse(context.Background())
+	if _, err := NewJsonStats(p, cfg).WithState(st).Generate(context.Background()); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := st.Save(path); err != nil {
+		t.Fatalf("Error saving state: %v", err)
+	}
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("Error loading state: %v", err)
+	}
+	if got := NewJsonStats(nil, cfg).WithState(loaded).Response().CountPerRecipe; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, but got %+v from the saved state", expected, got)
+	}
+	for _, count := range NewJsonStats(nil, cfg.WithShowAliases(false)).WithState(loaded).Response().CountPerRecipe {
+		if count.Aliases != nil {
+			t.Errorf("Expected no aliases without ShowAliases, but got %+v", count)
+		}
+	}
+}
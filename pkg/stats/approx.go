@@ -0,0 +1,159 @@
+package stats
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+	"github.com/rashad-j/jsonreader/pkg/sketch"
+)
+
+// ApproxStats generates estimated stats in fixed memory. Distinct counts come from
+// HyperLogLog and the busiest postcode/recipe from Count-Min sketches with a
+// heavy-hitters heap, so memory does not grow with the number of postcodes.
+type ApproxStats struct {
+	base *JsonStats
+}
+
+func NewApproxStats(p parser.Parser, cfg config.Config) *ApproxStats {
+	return &ApproxStats{
+		base: NewJsonStats(p, cfg),
+	}
+}
+
+// WithMetrics times every Generate call in m
+func (s *ApproxStats) WithMetrics(m metrics.Metrics) *ApproxStats {
+	s.base.WithMetrics(m)
+	return s
+}
+
+func (s *ApproxStats) Generate(ctx context.Context) (ApproxResponseData, error) {
+	defer s.base.observeQuery(time.Now())
+
+	cfg := s.base.cfg
+	recipeDistinct, err := sketch.NewHyperLogLog(cfg.ApproxPrecision)
+	if err != nil {
+		return ApproxResponseData{}, errors.Wrap(err, "failed to create recipe HyperLogLog")
+	}
+	postcodeDistinct, err := sketch.NewHyperLogLog(cfg.ApproxPrecision)
+	if err != nil {
+		return ApproxResponseData{}, errors.Wrap(err, "failed to create postcode HyperLogLog")
+	}
+	recipeSketch, err := sketch.NewCountMin(cfg.ApproxEpsilon, cfg.ApproxDelta)
+	if err != nil {
+		return ApproxResponseData{}, errors.Wrap(err, "failed to create recipe Count-Min sketch")
+	}
+	postcodeSketch, err := sketch.NewCountMin(cfg.ApproxEpsilon, cfg.ApproxDelta)
+	if err != nil {
+		return ApproxResponseData{}, errors.Wrap(err, "failed to create postcode Count-Min sketch")
+	}
+	topRecipes := sketch.NewTopK(max(cfg.ApproxTop, 1), recipeSketch)
+	topPostcodes := sketch.NewTopK(max(cfg.ApproxTop, 1), postcodeSketch)
+
+	specificPostCodeDeliveries := 0
+	// matching names are bounded by the distinct recipe names (< 2K), so they stay exact
+	recipesContainingWords := make(map[string]int)
+	wordsMap := toWordsMap(cfg.Words)
+
+	stream := s.base.parser.Stream()
+	var inputErr error
+	for {
+		entry, ok, err := next(ctx, stream)
+		if err != nil {
+			return ApproxResponseData{}, err
+		}
+		if !ok {
+			break
+		}
+		if entry.Error != nil {
+			inputErr = entryError(entry.Error, inputErr)
+			continue
+		}
+
+		recipeDistinct.Add(entry.Recipe.Recipe)
+		postcodeDistinct.Add(entry.Recipe.Postcode)
+		topRecipes.Add(entry.Recipe.Recipe)
+		topPostcodes.Add(entry.Recipe.Postcode)
+
+		if s.base.containsWords(entry.Recipe.Recipe, wordsMap) {
+			recipesContainingWords[entry.Recipe.Recipe]++
+		}
+		if entry.Recipe.Postcode == cfg.Postcode {
+			inRange, err := s.base.isDeliveryTimeInRange(entry.Recipe.Delivery, cfg.FromTime, cfg.ToTime)
+			if err != nil {
+				return ApproxResponseData{}, errors.Wrapf(err, "failed to check if delivery time is in range: %s", entry.Recipe.Delivery)
+			}
+			if inRange {
+				specificPostCodeDeliveries++
+			}
+		}
+	}
+
+	if inputErr != nil {
+		return ApproxResponseData{}, inputErr
+	}
+
+	responseData := ApproxResponseData{
+		TotalDeliveries:     recipeSketch.Total(),
+		UniqueRecipeCount:   distinctEstimate(recipeDistinct),
+		UniquePostcodeCount: distinctEstimate(postcodeDistinct),
+		TopRecipes:          make([]ApproxRecipeCount, 0, cfg.ApproxTop),
+		CountPerPostcodeAndTime: CountPerPostcodeAndTime{
+			Postcode:      cfg.Postcode,
+			From:          cfg.FromTime,
+			To:            cfg.ToTime,
+			DeliveryCount: specificPostCodeDeliveries,
+		},
+		MatchByName: sortKeys(recipesContainingWords),
+	}
+	for i, item := range topRecipes.Items() {
+		if i == cfg.ApproxTop {
+			break
+		}
+		responseData.TopRecipes = append(responseData.TopRecipes, ApproxRecipeCount{
+			Recipe: item.Key,
+			Count:  countEstimate(item.Count, recipeSketch),
+		})
+	}
+	var st State
+	st.addReport(s.base.parser)
+	if cfg.Normalize {
+		responseData.Normalized = st.normalized()
+	}
+	if cfg.Dedupe {
+		responseData.Dedupe = st.deduped()
+	}
+	if len(responseData.TopRecipes) > 0 {
+		responseData.BusiestRecipe = responseData.TopRecipes[0]
+	}
+	if items := topPostcodes.Items(); len(items) > 0 {
+		responseData.BusiestPostcode = ApproxBusiestPostcode{
+			Postcode:      items[0].Key,
+			DeliveryCount: countEstimate(items[0].Count, postcodeSketch),
+		}
+	}
+
+	return responseData, nil
+}
+
+// distinctEstimate reports a HyperLogLog count with a two standard errors bound (~95%)
+func distinctEstimate(hll *sketch.HyperLogLog) Estimate {
+	count := hll.Count()
+	return Estimate{
+		Estimate:   count,
+		ErrorBound: int(2*hll.RelativeError()*float64(count) + 0.5),
+		Confidence: 0.95,
+	}
+}
+
+// countEstimate reports a Count-Min estimate, which overcounts by at most the error bound
+func countEstimate(count int, cms *sketch.CountMin) Estimate {
+	return Estimate{
+		Estimate:   count,
+		ErrorBound: cms.ErrorBound(),
+		Confidence: cms.Confidence(),
+	}
+}
@@ -0,0 +1,55 @@
+package stats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+func TestApproxStats_Generate(t *testing.T) {
+	cfg := config.Config{
+		File:            "./testdata/test.json",
+		Words:           []string{"Potato", "Mushroom", "Veggie"},
+		Postcode:        "10120",
+		FromTime:        "10AM",
+		ToTime:          "3PM",
+		ApproxPrecision: 14,
+		ApproxEpsilon:   0.0001,
+		ApproxDelta:     0.01,
+		ApproxTop:       3,
+	}
+
+	exactParser := parser.NewJsonParser(cfg)
+	go exactParser.Parse(context.Background())
+	exact, err := NewJsonStats(exactParser, cfg).Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	approxParser := parser.NewJsonParser(cfg)
+	go approxParser.Parse(context.Background())
+	approx, err := NewApproxStats(approxParser, cfg).Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	unique := approx.UniqueRecipeCount
+	if diff := unique.Estimate - exact.UniqueRecipeCount; diff > unique.ErrorBound || -diff > unique.ErrorBound {
+		t.Errorf("Expected unique recipes %d ± %d, but got %d", exact.UniqueRecipeCount, unique.ErrorBound, unique.Estimate)
+	}
+	busiest := approx.BusiestPostcode.DeliveryCount
+	if busiest.Estimate < exact.BusiestPostcode.DeliveryCount || busiest.Estimate > exact.BusiestPostcode.DeliveryCount+busiest.ErrorBound {
+		t.Errorf("Expected busiest postcode count %d + %d, but got %d", exact.BusiestPostcode.DeliveryCount, busiest.ErrorBound, busiest.Estimate)
+	}
+	if len(approx.TopRecipes) != cfg.ApproxTop {
+		t.Errorf("Expected %d top recipes, but got %d", cfg.ApproxTop, len(approx.TopRecipes))
+	}
+	if approx.CountPerPostcodeAndTime != exact.CountPerPostcodeAndTime {
+		t.Errorf("Expected %v, but got %v", exact.CountPerPostcodeAndTime, approx.CountPerPostcodeAndTime)
+	}
+	if len(approx.MatchByName) != len(exact.MatchByName) {
+		t.Errorf("Expected %v, but got %v", exact.MatchByName, approx.MatchByName)
+	}
+}
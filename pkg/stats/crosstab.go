@@ -0,0 +1,136 @@
+package stats
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/rashad-j/jsonreader/pkg/parser"
+	"github.com/rs/zerolog/log"
+)
+
+// Crosstab counts recipes per postcode for a fixed set of postcodes.
+// Only the selected postcodes get a recipe breakdown, so memory is bounded by
+// len(postcodes) × distinct recipes (< 2K) instead of every postcode/recipe pair.
+type Crosstab struct {
+	postcodes  []string
+	counts     map[string]map[string]int
+	topRecipes int
+}
+
+// NewCrosstab creates a Crosstab for the given postcodes, keeping the topRecipes
+// most delivered recipes per postcode. topRecipes <= 0 keeps all recipes. A
+// repeated postcode is listed once, at its first position.
+func NewCrosstab(postcodes []string, topRecipes int) *Crosstab {
+	counts := make(map[string]map[string]int, len(postcodes))
+	unique := make([]string, 0, len(postcodes))
+	for _, postcode := range postcodes {
+		if _, ok := counts[postcode]; ok {
+			continue
+		}
+		counts[postcode] = make(map[string]int)
+		unique = append(unique, postcode)
+	}
+
+	return &Crosstab{
+		postcodes:  unique,
+		counts:     counts,
+		topRecipes: topRecipes,
+	}
+}
+
+// Add counts the recipe if its postcode is one of the selected postcodes
+func (c *Crosstab) Add(recipe parser.Recipe) {
+	if recipes, ok := c.counts[recipe.Postcode]; ok {
+		recipes[recipe.Recipe]++
+	}
+}
+
+// Count reads the whole parser stream into the crosstab. It is used for the second
+// pass of the top-N mode, once the busiest postcodes are known.
+func (c *Crosstab) Count(ctx context.Context, p parser.Parser) error {
+	stream := p.Stream()
+	for {
+		entry, ok, err := next(ctx, stream)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if entry.Error != nil {
+			log.Error().Err(entry.Error).Msg("failed to process entry")
+			continue
+		}
+		c.Add(entry.Recipe)
+	}
+}
+
+// Result returns the recipe counts per postcode, in the order the postcodes were
+// given. Recipes are ordered by count, most delivered first, then by name.
+func (c *Crosstab) Result() []PostcodeRecipes {
+	result := make([]PostcodeRecipes, 0, len(c.postcodes))
+	for _, postcode := range c.postcodes {
+		recipes := c.counts[postcode]
+		total := 0
+		for _, count := range recipes {
+			total += count
+		}
+
+		result = append(result, PostcodeRecipes{
+			Postcode:      postcode,
+			DeliveryCount: total,
+			Recipes:       topCounts(recipes, c.topRecipes),
+		})
+	}
+
+	return result
+}
+
+// TopPostcodes returns the n postcodes with most deliveries in the aggregated
+// state, ties broken alphabetically.
+func (s *JsonStats) TopPostcodes(n int) []string {
+	if s.state == nil {
+		return nil
+	}
+	ranked := rankCounts(s.state.PostcodeCounts, n)
+	postcodes := make([]string, 0, len(ranked))
+	for _, kc := range ranked {
+		postcodes = append(postcodes, kc.key)
+	}
+	return postcodes
+}
+
+// topCounts returns the n recipes with the highest count, ties broken alphabetically.
+// n <= 0 returns all recipes.
+func topCounts(recipeCounts map[string]int, n int) []RecipeCount {
+	ranked := rankCounts(recipeCounts, n)
+	recipes := make([]RecipeCount, 0, len(ranked))
+	for _, kc := range ranked {
+		recipes = append(recipes, RecipeCount{Recipe: kc.key, Count: kc.count})
+	}
+	return recipes
+}
+
+type keyCount struct {
+	key   string
+	count int
+}
+
+// rankCounts a helper function to order map entries by count (descending), then key
+func rankCounts(m map[string]int, n int) []keyCount {
+	ranked := make([]keyCount, 0, len(m))
+	for k, v := range m {
+		ranked = append(ranked, keyCount{key: k, count: v})
+	}
+	slices.SortFunc(ranked, func(a, b keyCount) int {
+		if a.count != b.count {
+			return cmp.Compare(b.count, a.count)
+		}
+		return cmp.Compare(a.key, b.key)
+	})
+	if n > 0 && len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+func TestCrosstab_Result(t *testing.T) {
+	recipes := []parser.Recipe{
+		{Postcode: "10120", Recipe: "RecipeB"},
+		{Postcode: "10120", Recipe: "RecipeA"},
+		{Postcode: "10120", Recipe: "RecipeB"},
+		{Postcode: "10120", Recipe: "RecipeC"},
+		{Postcode: "10121", Recipe: "RecipeA"},
+		{Postcode: "10999", Recipe: "RecipeA"},
+	}
+	tests := []struct {
+		name       string
+		postcodes  []string
+		topRecipes int
+		expected   []PostcodeRecipes
+	}{
+		{
+			name:       "all recipes",
+			postcodes:  []string{"10121", "10120"},
+			topRecipes: 0,
+			expected: []PostcodeRecipes{
+				{Postcode: "10121", DeliveryCount: 1, Recipes: []RecipeCount{{Recipe: "RecipeA", Count: 1}}},
+				{Postcode: "10120", DeliveryCount: 4, Recipes: []RecipeCount{
+					{Recipe: "RecipeB", Count: 2},
+					{Recipe: "RecipeA", Count: 1},
+					{Recipe: "RecipeC", Count: 1},
+				}},
+			},
+		},
+		{
+			name:       "top recipes",
+			postcodes:  []string{"10120"},
+			topRecipes: 2,
+			expected: []PostcodeRecipes{
+				{Postcode: "10120", DeliveryCount: 4, Recipes: []RecipeCount{
+					{Recipe: "RecipeB", Count: 2},
+					{Recipe: "RecipeA", Count: 1},
+				}},
+			},
+		},
+		{
+			name:       "repeated postcode",
+			postcodes:  []string{"10121", "10120", "10121"},
+			topRecipes: 1,
+			expected: []PostcodeRecipes{
+				{Postcode: "10121", DeliveryCount: 1, Recipes: []RecipeCount{{Recipe: "RecipeA", Count: 1}}},
+				{Postcode: "10120", DeliveryCount: 4, Recipes: []RecipeCount{{Recipe: "RecipeB", Count: 2}}},
+			},
+		},
+		{
+			name:       "unknown postcode",
+			postcodes:  []string{"99999"},
+			topRecipes: 2,
+			expected:   []PostcodeRecipes{{Postcode: "99999", Recipes: []RecipeCount{}}},
+		},
+	}
+
+	for _, tt := range tests {
+		// avoid closure
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ct := NewCrosstab(tt.postcodes, tt.topRecipes)
+			for _, recipe := range recipes {
+				ct.Add(recipe)
+			}
+			if got := ct.Result(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestJsonStats_TopPostcodes(t *testing.T) {
+	s := &JsonStats{state: &State{PostcodeCounts: map[string]int{"10122": 3, "10120": 5, "10121": 3, "10123": 1}}}
+
+	got := s.TopPostcodes(3)
+	expected := []string{"10120", "10121", "10122"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
@@ -0,0 +1,77 @@
+package stats
+
+import "slices"
+
+// Diff compares two stats runs: recipes that appeared or disappeared, count deltas
+// of recipes present in both, and changes of the busiest postcode and the
+// postcode/time delivery count
+func Diff(oldData, newData ResponseData) DiffData {
+	oldCounts := recipeCountsMap(oldData.CountPerRecipe)
+	newCounts := recipeCountsMap(newData.CountPerRecipe)
+
+	diff := DiffData{
+		UniqueRecipeCount: CountDelta{
+			Old:   oldData.UniqueRecipeCount,
+			New:   newData.UniqueRecipeCount,
+			Delta: newData.UniqueRecipeCount - oldData.UniqueRecipeCount,
+		},
+		AddedRecipes:   []RecipeCount{},
+		RemovedRecipes: []RecipeCount{},
+		CountDeltas:    []RecipeDelta{},
+		BusiestPostcode: BusiestPostcodeDiff{
+			Changed: oldData.BusiestPostcode.Postcode != newData.BusiestPostcode.Postcode,
+			Old:     oldData.BusiestPostcode,
+			New:     newData.BusiestPostcode,
+		},
+		CountPerPostcodeAndTime: PostcodeAndTimeDiff{
+			Old:   oldData.CountPerPostcodeAndTime,
+			New:   newData.CountPerPostcodeAndTime,
+			Delta: newData.CountPerPostcodeAndTime.DeliveryCount - oldData.CountPerPostcodeAndTime.DeliveryCount,
+		},
+		AddedMatches:   missingFrom(newData.MatchByName, oldData.MatchByName),
+		RemovedMatches: missingFrom(oldData.MatchByName, newData.MatchByName),
+	}
+
+	// recipes are alphabetically ordered in both runs, so are the results
+	for _, rc := range newData.CountPerRecipe {
+		oldCount, ok := oldCounts[rc.Recipe]
+		if !ok {
+			diff.AddedRecipes = append(diff.AddedRecipes, rc)
+			continue
+		}
+		if rc.Count != oldCount {
+			diff.CountDeltas = append(diff.CountDeltas, RecipeDelta{
+				Recipe: rc.Recipe,
+				Old:    oldCount,
+				New:    rc.Count,
+				Delta:  rc.Count - oldCount,
+			})
+		}
+	}
+	for _, rc := range oldData.CountPerRecipe {
+		if _, ok := newCounts[rc.Recipe]; !ok {
+			diff.RemovedRecipes = append(diff.RemovedRecipes, rc)
+		}
+	}
+
+	return diff
+}
+
+func recipeCountsMap(recipeCounts []RecipeCount) map[string]int {
+	counts := make(map[string]int, len(recipeCounts))
+	for _, rc := range recipeCounts {
+		counts[rc.Recipe] = rc.Count
+	}
+	return counts
+}
+
+// missingFrom returns the names of a that are not in b
+func missingFrom(a, b []string) []string {
+	missing := []string{}
+	for _, name := range a {
+		if !slices.Contains(b, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	oldData := ResponseData{
+		UniqueRecipeCount: 3,
+		CountPerRecipe: []RecipeCount{
+			{Recipe: "RecipeA", Count: 2},
+			{Recipe: "RecipeB", Count: 5},
+			{Recipe: "RecipeC", Count: 1},
+		},
+		BusiestPostcode:         BusiestPostcode{Postcode: "10120", DeliveryCount: 4},
+		CountPerPostcodeAndTime: CountPerPostcodeAndTime{Postcode: "10120", From: "10AM", To: "3PM", DeliveryCount: 2},
+		MatchByName:             []string{"RecipeA", "RecipeC"},
+	}
+	newData := ResponseData{
+		UniqueRecipeCount: 3,
+		CountPerRecipe: []RecipeCount{
+			{Recipe: "RecipeA", Count: 2},
+			{Recipe: "RecipeB", Count: 7},
+			{Recipe: "RecipeD", Count: 3},
+		},
+		BusiestPostcode:         BusiestPostcode{Postcode: "10121", DeliveryCount: 6},
+		CountPerPostcodeAndTime: CountPerPostcodeAndTime{Postcode: "10120", From: "10AM", To: "3PM", DeliveryCount: 5},
+		MatchByName:             []string{"RecipeA", "RecipeD"},
+	}
+
+	expected := DiffData{
+		UniqueRecipeCount: CountDelta{Old: 3, New: 3, Delta: 0},
+		AddedRecipes:      []RecipeCount{{Recipe: "RecipeD", Count: 3}},
+		RemovedRecipes:    []RecipeCount{{Recipe: "RecipeC", Count: 1}},
+		CountDeltas:       []RecipeDelta{{Recipe: "RecipeB", Old: 5, New: 7, Delta: 2}},
+		BusiestPostcode: BusiestPostcodeDiff{
+			Changed: true,
+			Old:     oldData.BusiestPostcode,
+			New:     newData.BusiestPostcode,
+		},
+		CountPerPostcodeAndTime: PostcodeAndTimeDiff{
+			Old:   oldData.CountPerPostcodeAndTime,
+			New:   newData.CountPerPostcodeAndTime,
+			Delta: 3,
+		},
+		AddedMatches:   []string{"RecipeD"},
+		RemovedMatches: []string{"RecipeC"},
+	}
+
+	if got := Diff(oldData, newData); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, got)
+	}
+}
+
+func TestDiff_SameRun(t *testing.T) {
+	data := ResponseData{
+		UniqueRecipeCount: 1,
+		CountPerRecipe:    []RecipeCount{{Recipe: "RecipeA", Count: 2}},
+		BusiestPostcode:   BusiestPostcode{Postcode: "10120", DeliveryCount: 2},
+		MatchByName:       []string{"RecipeA"},
+	}
+
+	got := Diff(data, data)
+	if len(got.AddedRecipes)+len(got.RemovedRecipes)+len(got.CountDeltas)+len(got.AddedMatches)+len(got.RemovedMatches) != 0 {
+		t.Errorf("Expected no changes, but got %+v", got)
+	}
+	if got.BusiestPostcode.Changed || got.CountPerPostcodeAndTime.Delta != 0 {
+		t.Errorf("Expected no changes, but got %+v", got)
+	}
+}
@@ -0,0 +1,55 @@
+package stats
+
+import (
+	"math"
+	"slices"
+
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+// RecipeExtras sums the optional fields of the records of one recipe. Revenue is
+// summed in cents, so the total does not depend on the order of the records.
+type RecipeExtras struct {
+	BoxUnits     int   `json:"box_units"`
+	RevenueCents int64 `json:"revenue_cents"`
+}
+
+// addExtras adds the box size and price of a record, if it has them
+func (st *State) addExtras(recipe parser.Recipe) {
+	extra := recipe.Extra
+	if extra.BoxSize == nil && extra.Price == nil {
+		return
+	}
+	sums := st.Extras[recipe.Recipe]
+	if extra.BoxSize != nil {
+		sums.BoxUnits += *extra.BoxSize
+	}
+	if extra.Price != nil {
+		sums.RevenueCents += int64(math.Round(*extra.Price * 100))
+	}
+	st.Extras[recipe.Recipe] = sums
+}
+
+// extendedStats returns the totals and the sums per recipe, sorted by recipe name
+func (st *State) extendedStats() *ExtendedStats {
+	recipes := make([]string, 0, len(st.Extras))
+	for recipe := range st.Extras {
+		recipes = append(recipes, recipe)
+	}
+	slices.Sort(recipes)
+
+	result := &ExtendedStats{PerRecipe: make([]RecipeExtended, 0, len(recipes))}
+	var revenueCents int64
+	for _, recipe := range recipes {
+		sums := st.Extras[recipe]
+		result.TotalBoxUnits += sums.BoxUnits
+		revenueCents += sums.RevenueCents
+		result.PerRecipe = append(result.PerRecipe, RecipeExtended{
+			Recipe:   recipe,
+			BoxUnits: sums.BoxUnits,
+			Revenue:  float64(sums.RevenueCents) / 100,
+		})
+	}
+	result.TotalRevenue = float64(revenueCents) / 100
+	return result
+}
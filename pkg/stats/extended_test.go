@@ -0,0 +1,79 @@
+package stats
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+func TestJsonStats_Extended(t *testing.T) {
+	expected := &ExtendedStats{
+		TotalBoxUnits: 9,
+		TotalRevenue:  80.28,
+		PerRecipe: []RecipeExtended{
+			{Recipe: "Cherry Balsamic Pork Chops", Revenue: 0.3},
+			{Recipe: "Creamy Dill Chicken", BoxUnits: 6, Revenue: 79.98},
+			{Recipe: "Speedy Steak Fajitas", BoxUnits: 3},
+		},
+	}
+	cfg := config.Default().WithFile("./testdata/extended.json").WithExtended(true).WithLogRejections(0)
+
+	for _, engine := range []string{"json", "fast"} {
+		cfg := cfg.WithEngine(engine)
+		p := parser.NewJsonParser(cfg)
+		go p.Parse(context.Background())
+		got, err := NewJsonStats(p, cfg).Generate(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(got.Extended, expected) {
+			t.Errorf("Expected %+v, but got %+v with the %s engine", expected, got.Extended, engine)
+		}
+	}
+
+	// the sums survive a saved state, and profiles share them
+	st := NewState(cfg)
+	p := parser.NewJsonParser(cfg)
+	go p.Parse(context.Background())
+	if _, err := NewJsonStats(p, cfg).WithState(st).Generate(context.Background()); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := st.Save(path); err != nil {
+		t.Fatalf("Error saving state: %v", err)
+	}
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("Error loading state: %v", err)
+	}
+	if got := NewJsonStats(nil, cfg).WithState(loaded).Response().Extended; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, but got %+v from the saved state", expected, got)
+	}
+
+	p = parser.NewJsonParser(cfg)
+	go p.Parse(context.Background())
+	profiles, err := NewProfileStats(p, map[string]config.Config{"lunch": cfg}).Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if !reflect.DeepEqual(profiles["lunch"].Extended, expected) {
+		t.Errorf("Expected %+v, but got %+v with profiles", expected, profiles["lunch"].Extended)
+	}
+}
+
+func TestJsonStats_ExtendedDisabled(t *testing.T) {
+	cfg := config.Default().WithFile("./testdata/extended.json")
+	p := parser.NewJsonParser(cfg)
+	go p.Parse(context.Background())
+	got, err := NewJsonStats(p, cfg).Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if got.Extended != nil {
+		t.Errorf("Expected no extended stats, but got %+v", got.Extended)
+	}
+}
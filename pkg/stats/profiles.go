@@ -0,0 +1,95 @@
+package stats
+
+import (
+	"context"
+	"time"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+// ProfileStats generates the stats of several profiles in one pass over the stream.
+// Recipe and postcode counts are shared, only the word matches and the
+// postcode/time counter are kept per profile.
+type ProfileStats struct {
+	parser   parser.Parser
+	profiles map[string]config.Config
+	metrics  metrics.Metrics
+}
+
+func NewProfileStats(p parser.Parser, profiles map[string]config.Config) *ProfileStats {
+	return &ProfileStats{
+		parser:   p,
+		profiles: profiles,
+		metrics:  metrics.Nop{},
+	}
+}
+
+// WithMetrics times every Generate call in m
+func (s *ProfileStats) WithMetrics(m metrics.Metrics) *ProfileStats {
+	s.metrics = m
+	return s
+}
+
+// Generate returns the stats per profile name
+func (s *ProfileStats) Generate(ctx context.Context) (map[string]ResponseData, error) {
+	start := time.Now()
+	defer func() {
+		s.metrics.ObserveQuery(time.Since(start))
+	}()
+
+	shared := NewJsonStats(nil, config.Config{}).WithState(NewState(config.Config{}))
+	profiles := make(map[string]*JsonStats, len(s.profiles))
+	wordsMaps := make(map[string]map[string]bool, len(s.profiles))
+	for name, cfg := range s.profiles {
+		profiles[name] = NewJsonStats(nil, cfg).WithState(&State{
+			Version:        stateVersion,
+			Query:          queryFromConfig(cfg),
+			RecipeCounts:   shared.state.RecipeCounts,
+			PostcodeCounts: shared.state.PostcodeCounts,
+			MatchCounts:    make(map[string]int),
+			Extras:         shared.state.Extras,
+			AliasCounts:    shared.state.AliasCounts,
+		})
+		wordsMaps[name] = toWordsMap(cfg.Words)
+	}
+
+	stream := s.parser.Stream()
+	var inputErr error
+	for {
+		entry, ok, err := next(ctx, stream)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if entry.Error != nil {
+			inputErr = entryError(entry.Error, inputErr)
+			continue
+		}
+
+		shared.addCounts(entry.Recipe)
+		for name, profile := range profiles {
+			if err := profile.addQuery(entry.Recipe, wordsMaps[name]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if inputErr != nil {
+		return nil, inputErr
+	}
+	shared.state.addReport(s.parser)
+
+	result := make(map[string]ResponseData, len(profiles))
+	for name, profile := range profiles {
+		profile.state.BusiestPostcode = shared.state.BusiestPostcode
+		profile.state.Normalized = shared.state.Normalized
+		profile.state.Deduped = shared.state.Deduped
+		result[name] = profile.Response()
+	}
+
+	return result, nil
+}
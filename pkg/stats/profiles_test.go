@@ -0,0 +1,38 @@
+package stats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+func TestProfileStats_Generate(t *testing.T) {
+	base := config.Config{File: "./testdata/test.json"}
+	profiles := map[string]config.Config{
+		"berlin-lunch": base.WithPostcode("10120").WithFromTime("10AM").WithToTime("3PM").WithWords([]string{"Potato", "Veggie"}),
+		"evening":      base.WithPostcode("10216").WithFromTime("6PM").WithToTime("7PM").WithWords([]string{"Chicken"}),
+	}
+
+	p := parser.NewJsonParser(base)
+	go p.Parse(context.Background())
+	got, err := NewProfileStats(p, profiles).Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	// every profile must match a separate run with its own config
+	for name, cfg := range profiles {
+		single := parser.NewJsonParser(cfg)
+		go single.Parse(context.Background())
+		expected, err := NewJsonStats(single, cfg).Generate(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(got[name], expected) {
+			t.Errorf("%s: expected %+v, but got %+v", name, expected, got[name])
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+// referenceRecord is a generated recipe with the facts the parser derives from it,
+// known by construction: whether it is valid, and its delivery hours of the day
+type referenceRecord struct {
+	parser.Recipe
+	Valid bool
+	Start int
+	End   int
+}
+
+// referenceStats computes the five stats the slow and obvious way, on the whole list
+// of records in memory, as the oracle for the streaming JsonStats.Generate
+func referenceStats(records []referenceRecord, cfg config.Config, from, to int) ResponseData {
+	var valid []referenceRecord
+	for _, r := range records {
+		if r.Valid {
+			valid = append(valid, r)
+		}
+	}
+
+	// 1. unique recipe names and 2. the count of each, by name
+	var names []string
+	for _, r := range valid {
+		if !slices.Contains(names, r.Recipe.Recipe) {
+			names = append(names, r.Recipe.Recipe)
+		}
+	}
+	slices.Sort(names)
+	countPerRecipe := []RecipeCount{}
+	for _, name := range names {
+		count := 0
+		for _, r := range valid {
+			if r.Recipe.Recipe == name {
+				count++
+			}
+		}
+		countPerRecipe = append(countPerRecipe, RecipeCount{Recipe: name, Count: count})
+	}
+
+	// 3. the postcode with most deliveries, the first in alphabetical order on a tie
+	busiest := BusiestPostcode{}
+	for _, r := range valid {
+		count := 0
+		for _, other := range valid {
+			if other.Postcode == r.Postcode {
+				count++
+			}
+		}
+		if count > busiest.DeliveryCount || (count == busiest.DeliveryCount && r.Postcode < busiest.Postcode) {
+			busiest = BusiestPostcode{Postcode: r.Postcode, DeliveryCount: count}
+		}
+	}
+
+	// 4. deliveries to the postcode whose window starts by from and ends after to
+	postcodeAndTime := CountPerPostcodeAndTime{Postcode: cfg.Postcode, From: cfg.FromTime, To: cfg.ToTime}
+	for _, r := range valid {
+		if r.Postcode == cfg.Postcode && r.Start <= from && to < r.End {
+			postcodeAndTime.DeliveryCount++
+		}
+	}
+
+	// 5. recipe names with a word equal to one of the words, ignoring case, null in
+	// JSON when there are none
+	var matches []string
+	for _, name := range names {
+		for _, word := range strings.Fields(name) {
+			if slices.ContainsFunc(cfg.Words, func(w string) bool { return strings.EqualFold(w, word) }) {
+				matches = append(matches, name)
+				break
+			}
+		}
+	}
+
+	return ResponseData{
+		UniqueRecipeCount:       len(names),
+		CountPerRecipe:          countPerRecipe,
+		BusiestPostcode:         busiest,
+		CountPerPostcodeAndTime: postcodeAndTime,
+		MatchByName:             matches,
+	}
+}
+
+// hourLabels are the hours of the day, 12AM is midnight and 12PM is noon
+var hourLabels = []string{
+	"12AM", "1AM", "2AM", "3AM", "4AM", "5AM", "6AM", "7AM", "8AM", "9AM", "10AM", "11AM",
+	"12PM", "1PM", "2PM", "3PM", "4PM", "5PM", "6PM", "7PM", "8PM", "9PM", "10PM", "11PM",
+}
+
+var (
+	referencePostcodes = []struct {
+		postcode string
+		valid    bool
+	}{
+		{"10120", true}, {"10121", true}, {"10122", true}, {"A1 2B", true},
+		{"", false}, {"12345678901", false},
+	}
+	referenceRecipes = []struct {
+		recipe string
+		valid  bool
+	}{
+		{"Potato Soup", true}, {"Creamy Mushroom Risotto", true}, {"veggie burger", true},
+		{"Potatoes Gratin", true}, {"  Steak   Frites ", true}, {"Meatloaf à La Mom", true},
+		{"", false}, {strings.Repeat("Veggie", 17), false},
+	}
+)
+
+// referenceCase decodes fuzz data into a query and records: 3 bytes for the query
+// postcode and time window, then 5 bytes per record
+func referenceCase(data []byte) (config.Config, int, int, []referenceRecord) {
+	if len(data) < 3 {
+		data = append(data, make([]byte, 3-len(data))...)
+	}
+	from, to := int(data[1])%24, int(data[2])%24
+	cfg := config.Default().
+		WithPostcode(referencePostcodes[int(data[0])%4].postcode).
+		WithFromTime(hourLabels[from]).
+		WithToTime(hourLabels[to])
+
+	var records []referenceRecord
+	for b := data[3:]; len(b) >= 5; b = b[5:] {
+		postcode := referencePostcodes[int(b[0])%len(referencePostcodes)]
+		recipe := referenceRecipes[int(b[1])%len(referenceRecipes)]
+		day := []string{"Monday", "Sunday"}[b[2]&1]
+		start, end := int(b[3])%12, 12+int(b[4])%12
+
+		r := referenceRecord{
+			Recipe: parser.Recipe{Postcode: postcode.postcode, Recipe: recipe.recipe},
+			Valid:  postcode.valid && recipe.valid,
+			Start:  start,
+			End:    end,
+		}
+		switch (b[2] >> 1) % 4 {
+		case 0, 1:
+			r.Delivery = fmt.Sprintf("%s %s - %s", day, hourLabels[start], hourLabels[end])
+		case 2:
+			r.Delivery = fmt.Sprintf("%s %s-%s", day, hourLabels[start], hourLabels[end])
+		case 3:
+			// the end before the start
+			r.Delivery = fmt.Sprintf("%s %s - %s", day, hourLabels[end], hourLabels[start])
+			r.Valid = false
+		}
+		records = append(records, r)
+	}
+	return cfg, from, to, records
+}
+
+// checkReference runs JsonStats.Generate with both parser engines on the records
+// of data and compares the result with referenceStats
+func checkReference(t *testing.T, data []byte) {
+	cfg, from, to, records := referenceCase(data)
+	recipes := make([]parser.Recipe, 0, len(records))
+	for _, r := range records {
+		recipes = append(recipes, r.Recipe)
+	}
+	content, err := json.Marshal(recipes)
+	if err != nil {
+		t.Fatalf("Error encoding recipes: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "recipes.json")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("Error writing recipes: %v", err)
+	}
+
+	expected, err := json.Marshal(referenceStats(records, cfg, from, to))
+	if err != nil {
+		t.Fatalf("Error encoding stats: %v", err)
+	}
+	for _, engine := range []string{"json", "fast"} {
+		cfg := cfg.WithFile(path).WithEngine(engine).WithLogRejections(0)
+		p := parser.NewJsonParser(cfg)
+		go p.Parse(context.Background())
+		data, err := NewJsonStats(p, cfg).Generate(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error with the %s engine, but got %v", engine, err)
+		}
+		got, err := json.Marshal(data)
+		if err != nil {
+			t.Fatalf("Error encoding stats: %v", err)
+		}
+		if string(got) != string(expected) {
+			t.Errorf("Expected %s, but got %s with the %s engine for %s", expected, got, engine, content)
+		}
+	}
+}
+
+func TestJsonStats_Reference(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "no records", data: []byte{0, 10, 15}},
+		// 10121 reaches 2 deliveries first, 10120 ties it later
+		{name: "busiest postcode tie", data: []byte{0, 10, 15, 1, 0, 0, 9, 5, 1, 1, 0, 9, 5, 0, 2, 0, 9, 5, 0, 3, 0, 9, 5}},
+		// 9AM - 12PM (noon) does not cover 10AM - 3PM
+		{name: "delivery ends at noon", data: []byte{0, 10, 15, 0, 0, 0, 9, 0}},
+		{name: "delivery starts at midnight", data: []byte{0, 0, 15, 0, 0, 0, 0, 5}},
+		{name: "delivery without spaces", data: []byte{0, 10, 15, 0, 0, 4, 9, 5}},
+		{name: "invalid records", data: []byte{0, 10, 15, 4, 0, 0, 9, 5, 0, 6, 0, 9, 5, 0, 0, 6, 9, 5}},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			checkReference(t, tt.data)
+		})
+	}
+}
+
+// FuzzJsonStats_Reference compares the stats of random recipe arrays with the
+// reference implementation, run it with go test ./pkg/stats -fuzz Reference
+func FuzzJsonStats_Reference(f *testing.F) {
+	f.Add([]byte{0, 10, 15})
+	f.Add([]byte{0, 10, 15, 1, 0, 0, 9, 5, 1, 1, 0, 9, 5, 0, 2, 0, 9, 5, 0, 3, 0, 9, 5})
+	f.Add([]byte{2, 0, 23, 2, 1, 2, 11, 11, 3, 4, 7, 0, 0, 5, 5, 5, 5, 5})
+	f.Fuzz(checkReference)
+}
@@ -0,0 +1,42 @@
+package stats
+
+import "github.com/rashad-j/jsonreader/pkg/parser"
+
+// addReport accumulates the report of p, read once its stream is closed
+func (st *State) addReport(p parser.Parser) {
+	reporter, ok := p.(parser.Reporter)
+	if !ok {
+		return
+	}
+	report := reporter.Report()
+	if report.Normalized != nil {
+		if st.Normalized == nil {
+			st.Normalized = &parser.NormalizeReport{}
+		}
+		st.Normalized.Merge(*report.Normalized)
+	}
+	if report.Deduped != nil {
+		if st.Deduped == nil {
+			st.Deduped = &parser.DedupeReport{}
+		}
+		st.Deduped.Merge(*report.Deduped)
+	}
+}
+
+// normalized returns the changes of normalization over every processed file
+func (st *State) normalized() *parser.NormalizeReport {
+	if st.Normalized == nil {
+		return &parser.NormalizeReport{}
+	}
+	normalized := *st.Normalized
+	return &normalized
+}
+
+// deduped returns the duplicates dropped over every processed file
+func (st *State) deduped() *parser.DedupeReport {
+	if st.Deduped == nil {
+		return &parser.DedupeReport{}
+	}
+	deduped := *st.Deduped
+	return &deduped
+}
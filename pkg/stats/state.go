@@ -0,0 +1,356 @@
+package stats
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+	"github.com/rs/zerolog/log"
+)
+
+// stateVersion is bumped whenever the State format changes, older states are recomputed
+const stateVersion = 2
+
+// State is the aggregation state of a stats run. It is persisted between runs so
+// only new input files need to be parsed.
+type State struct {
+	Version int         `json:"version"`
+	Query   Query       `json:"query"`
+	Files   []InputFile `json:"files"`
+
+	RecipeCounts      map[string]int `json:"recipe_counts"`
+	PostcodeCounts    map[string]int `json:"postcode_counts"`
+	BusiestPostcode   string         `json:"busiest_postcode"`
+	MatchCounts       map[string]int `json:"match_counts"`
+	PostcodeTimeCount int            `json:"postcode_time_count"`
+	// Extras sums the optional fields per recipe, see config.Extended
+	Extras map[string]RecipeExtras `json:"extras,omitempty"`
+	// AliasCounts counts the records per alias of each canonical recipe name
+	AliasCounts map[string]map[string]int `json:"alias_counts,omitempty"`
+	// Normalized counts the records changed by normalization, see config.Normalize
+	Normalized *parser.NormalizeReport `json:"normalized,omitempty"`
+	// Deduped counts the records dropped as duplicates, see config.Dedupe
+	Deduped *parser.DedupeReport `json:"dedupe,omitempty"`
+	// DedupeFilter references the file of the records seen by the dedupe filter, so
+	// duplicates of processed files are dropped from new ones. The filter has a
+	// fixed size of megabytes, so it is saved next to the state instead of in it.
+	DedupeFilter *SidecarFile `json:"dedupe_filter,omitempty"`
+
+	// filter is the encoded dedupe filter to save, see KeepDeduper
+	filter []byte
+	// dir is the directory of a loaded state, its sidecar files are read from there
+	dir string
+}
+
+// SidecarFile references a file saved next to the state by name and SHA-256 checksum,
+// the name contains the checksum so a new one never replaces a file still in use
+type SidecarFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// Query holds the parameters the word matches and the postcode/time count depend on,
+// and the field mapping, extra fields, rules, normalization, aliases and dedupe all
+// counts depend on
+type Query struct {
+	Postcode               string              `json:"postcode"`
+	From                   string              `json:"from"`
+	To                     string              `json:"to"`
+	Words                  []string            `json:"words"`
+	Fields                 config.FieldMapping `json:"fields"`
+	Extended               bool                `json:"extended,omitempty"`
+	Rules                  config.Rules        `json:"rules"`
+	Normalize              bool                `json:"normalize,omitempty"`
+	NormalizePostcodeWidth int                 `json:"normalize_postcode_width,omitempty"`
+	Aliases                config.Aliases      `json:"aliases,omitempty"`
+	Dedupe                 bool                `json:"dedupe,omitempty"`
+	DedupeCapacity         int                 `json:"dedupe_capacity,omitempty"`
+	DedupeFalsePositive    float64             `json:"dedupe_false_positive,omitempty"`
+}
+
+// InputFile identifies a processed file, a change in size or modification time
+// means it has to be processed again
+type InputFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+func NewState(cfg config.Config) *State {
+	return &State{
+		Version:        stateVersion,
+		Query:          queryFromConfig(cfg),
+		RecipeCounts:   make(map[string]int, 2000),
+		PostcodeCounts: make(map[string]int, 1000_000),
+		MatchCounts:    make(map[string]int),
+		Extras:         make(map[string]RecipeExtras),
+		AliasCounts:    make(map[string]map[string]int),
+	}
+}
+
+// LoadState reads a state saved by Save
+func LoadState(path string) (*State, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open state")
+	}
+	defer file.Close()
+
+	var st State
+	if err := json.NewDecoder(file).Decode(&st); err != nil {
+		return nil, errors.Wrap(err, "failed to decode state")
+	}
+	if st.Version != stateVersion {
+		return nil, errors.Errorf("unsupported state version %d", st.Version)
+	}
+	if st.RecipeCounts == nil || st.PostcodeCounts == nil || st.MatchCounts == nil {
+		return nil, errors.New("state is missing counts")
+	}
+	if st.Extras == nil {
+		st.Extras = make(map[string]RecipeExtras)
+	}
+	if st.AliasCounts == nil {
+		st.AliasCounts = make(map[string]map[string]int)
+	}
+	st.dir = filepath.Dir(path)
+
+	return &st, nil
+}
+
+// Save writes the state to a temporary file first, so an interrupted run never
+// leaves a truncated state behind. The dedupe filter is written to a new sidecar
+// file before, and the sidecar files of earlier states are removed after.
+func (st *State) Save(path string) error {
+	st.DedupeFilter = nil
+	if st.filter != nil {
+		sum := sha256.Sum256(st.filter)
+		ref := &SidecarFile{
+			Name:   filepath.Base(path) + dedupeSuffix + hex.EncodeToString(sum[:8]),
+			SHA256: hex.EncodeToString(sum[:]),
+		}
+		if err := writeFile(filepath.Join(filepath.Dir(path), ref.Name), func(w io.Writer) error {
+			_, err := w.Write(st.filter)
+			return err
+		}); err != nil {
+			return errors.Wrap(err, "failed to save dedupe filter")
+		}
+		st.DedupeFilter = ref
+	}
+
+	if err := writeFile(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(st)
+	}); err != nil {
+		return errors.Wrap(err, "failed to write state")
+	}
+	st.removeSidecars(path)
+
+	return nil
+}
+
+// dedupeSuffix is appended to the state file name to name its dedupe filter
+const dedupeSuffix = ".dedupe-"
+
+// removeSidecars removes the dedupe filters of the state file path but the current
+// one. A failure only leaves a stale file behind, so it is logged.
+func (st *State) removeSidecars(path string) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to remove stale dedupe filters")
+		return
+	}
+	prefix := filepath.Base(path) + dedupeSuffix
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (st.DedupeFilter != nil && name == st.DedupeFilter.Name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(filepath.Dir(path), name)); err != nil {
+			log.Warn().Err(err).Str("file", name).Msg("failed to remove stale dedupe filter")
+		}
+	}
+}
+
+// writeFile writes path through a temporary file renamed once complete
+func writeFile(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to encode file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write file")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrap(err, "failed to replace file")
+	}
+	return nil
+}
+
+// Pending returns the files that still have to be processed. An error means the
+// state cannot be reused and everything has to be recomputed: the query changed, the
+// dedupe filter is missing, a processed file changed or disappeared, or a new file
+// sorts before a processed one (files are processed in order, which decides the
+// record dedupe keeps and the display name normalization picks).
+func (st *State) Pending(cfg config.Config, files []InputFile) ([]InputFile, error) {
+	if !st.Query.equal(queryFromConfig(cfg)) {
+		return nil, errors.New("query parameters changed")
+	}
+	if cfg.Dedupe && len(st.Files) > 0 && st.DedupeFilter == nil {
+		return nil, errors.New("state is missing the dedupe filter")
+	}
+
+	current := make(map[string]InputFile, len(files))
+	for _, f := range files {
+		current[f.Path] = f
+	}
+	last := ""
+	for _, processed := range st.Files {
+		f, ok := current[processed.Path]
+		if !ok {
+			return nil, errors.Errorf("processed file %s is missing", processed.Path)
+		}
+		if f.Size != processed.Size || !f.ModTime.Equal(processed.ModTime) {
+			return nil, errors.Errorf("processed file %s changed", processed.Path)
+		}
+		last = max(last, processed.Path)
+	}
+
+	var pending []InputFile
+	for _, f := range files {
+		if slices.ContainsFunc(st.Files, func(processed InputFile) bool { return processed.Path == f.Path }) {
+			continue
+		}
+		if f.Path < last {
+			return nil, errors.Errorf("new file %s sorts before processed file %s", f.Path, last)
+		}
+		pending = append(pending, f)
+	}
+
+	return pending, nil
+}
+
+// MarkProcessed records a file whose records were added to the state
+func (st *State) MarkProcessed(f InputFile) {
+	st.Files = append(st.Files, f)
+}
+
+// StatInputFiles reads size and modification time of the given files
+func StatInputFiles(paths []string) ([]InputFile, error) {
+	files := make([]InputFile, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to stat input file")
+		}
+		files = append(files, InputFile{Path: path, Size: info.Size(), ModTime: info.ModTime().UTC()})
+	}
+	return files, nil
+}
+
+func queryFromConfig(cfg config.Config) Query {
+	// the alias file was validated, the parser reports it should it fail to load now
+	aliases, _ := cfg.LoadAliases()
+	q := Query{
+		Postcode: cfg.Postcode,
+		From:     cfg.FromTime,
+		To:       cfg.ToTime,
+		Words:    cfg.Words,
+		Fields:   cfg.FieldMapping,
+		Extended: cfg.Extended,
+		Rules:    cfg.Rules,
+
+		Normalize:              cfg.Normalize,
+		NormalizePostcodeWidth: cfg.NormalizePostcodeWidth,
+		Aliases:                aliases,
+		Dedupe:                 cfg.Dedupe,
+	}
+	if cfg.Dedupe {
+		// the filter of the state only takes records of the same settings
+		q.DedupeCapacity, q.DedupeFalsePositive = cfg.DedupeCapacity, cfg.DedupeFalsePositive
+	}
+	return q
+}
+
+// Deduper returns the deduper of cfg remembering the records of the processed files,
+// or nil without config.Dedupe
+func (st *State) Deduper(cfg config.Config) (*parser.Deduper, error) {
+	if !cfg.Dedupe {
+		return nil, nil
+	}
+	deduper, err := parser.NewDeduper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if st.DedupeFilter == nil {
+		return deduper, nil
+	}
+	filter, err := os.ReadFile(filepath.Join(st.dir, st.DedupeFilter.Name))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read dedupe filter")
+	}
+	if sum := sha256.Sum256(filter); hex.EncodeToString(sum[:]) != st.DedupeFilter.SHA256 {
+		return nil, errors.Errorf("dedupe filter %s does not match its checksum", st.DedupeFilter.Name)
+	}
+	if err := deduper.UnmarshalBinary(filter); err != nil {
+		return nil, err
+	}
+	return deduper, nil
+}
+
+// KeepDeduper keeps the records remembered by d for the next run, they are saved
+// with the state, see Deduper
+func (st *State) KeepDeduper(d *parser.Deduper) error {
+	if d == nil {
+		st.filter = nil
+		return nil
+	}
+	filter, err := d.MarshalBinary()
+	if err != nil {
+		return errors.Wrap(err, "failed to encode dedupe filter")
+	}
+	st.filter = filter
+	return nil
+}
+
+// RecipeNames returns the counted recipes, sorted
+func (st *State) RecipeNames() []string {
+	names := make([]string, 0, len(st.RecipeCounts))
+	for name := range st.RecipeCounts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// equal compares queries, words are matched case-insensitively so their case and order don't matter
+func (q Query) equal(other Query) bool {
+	if q.Postcode != other.Postcode || q.From != other.From || q.To != other.To || q.Fields != other.Fields || q.Extended != other.Extended {
+		return false
+	}
+	if q.Normalize != other.Normalize || q.NormalizePostcodeWidth != other.NormalizePostcodeWidth || !maps.Equal(q.Aliases, other.Aliases) || q.Dedupe != other.Dedupe {
+		return false
+	}
+	if q.DedupeCapacity != other.DedupeCapacity || q.DedupeFalsePositive != other.DedupeFalsePositive {
+		return false
+	}
+	if !reflect.DeepEqual(q.Rules, other.Rules) {
+		return false
+	}
+	return maps.Equal(toWordsMap(q.Words), toWordsMap(other.Words))
+}
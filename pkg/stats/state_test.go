@@ -0,0 +1,381 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/parser"
+)
+
+func TestState_IncrementalMatchesFullRecompute(t *testing.T) {
+	cfg := config.Config{
+		Words:    []string{"Potato", "Mushroom", "Veggie"},
+		Postcode: "10120",
+		FromTime: "10AM",
+		ToTime:   "3PM",
+	}
+
+	// split the test data into two daily files
+	content, err := os.ReadFile("./testdata/test.json")
+	if err != nil {
+		t.Fatalf("Error reading test data: %v", err)
+	}
+	var recipes []parser.Recipe
+	if err := json.Unmarshal(content, &recipes); err != nil {
+		t.Fatalf("Error decoding test data: %v", err)
+	}
+	dir := t.TempDir()
+	writeRecipes(t, filepath.Join(dir, "2024-01-01.json"), recipes[:40])
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	// first run only sees the first file
+	first := generateWithState(t, cfg.WithFile(dir), statePath)
+	if len(first.Files) != 1 {
+		t.Fatalf("Expected 1 processed file, but got %d", len(first.Files))
+	}
+
+	// second run only processes the new file
+	writeRecipes(t, filepath.Join(dir, "2024-01-02.json"), recipes[40:])
+	loaded, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	files := statFiles(t, dir)
+	pending, err := loaded.Pending(cfg, files)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if len(pending) != 1 || pending[0].Path != filepath.Join(dir, "2024-01-02.json") {
+		t.Fatalf("Expected only the new file to be pending, but got %v", pending)
+	}
+	s := NewJsonStats(nil, cfg).WithState(loaded)
+	p := parser.NewJsonParser(cfg.WithFile(pending[0].Path))
+	go p.Parse(context.Background())
+	incremental, err := s.WithParser(p).Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	full := parser.NewJsonParser(cfg.WithFile("./testdata/test.json"))
+	go full.Parse(context.Background())
+	expected, err := NewJsonStats(full, cfg).Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if !reflect.DeepEqual(incremental, expected) {
+		t.Errorf("Expected %v, but got %v", expected, incremental)
+	}
+}
+
+func TestState_IncrementalReport(t *testing.T) {
+	cfg := config.Config{Normalize: true, NormalizePostcodeWidth: 5}
+	first := []parser.Recipe{
+		{Postcode: "10224", Recipe: "Tex-Mex Tilapia", Delivery: "Wednesday 1AM - 7PM"},
+		{Postcode: "1024", Recipe: " Tex-Mex Tilapia", Delivery: "Wednesday 1AM - 7PM"},
+	}
+	second := []parser.Recipe{
+		{Postcode: "10224", Recipe: "TEX-MEX TILAPIA", Delivery: "Wednesday 1AM - 7PM"},
+		{Postcode: "10224", Recipe: "Tex-Mex Tilapia", Delivery: "Wednesday 1AM - 7PM"},
+	}
+
+	incremental, expected := incrementalAndFull(t, cfg, first, second)
+	if incremental.Normalized == nil || *incremental.Normalized != (parser.NormalizeReport{Records: 2, Whitespace: 1, Case: 1, Padding: 1}) {
+		t.Errorf("Expected the changes of both runs, but got %+v", incremental.Normalized)
+	}
+	if !reflect.DeepEqual(incremental, expected) {
+		t.Errorf("Expected %v, but got %v", expected, incremental)
+	}
+}
+
+func TestState_IncrementalDedupe(t *testing.T) {
+	cfg := config.Default().WithDedupe(true).WithDedupeCapacity(1000).WithLogRejections(0)
+	record := parser.Recipe{Postcode: "10224", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 1AM - 7PM"}
+	first := []parser.Recipe{record}
+	second := []parser.Recipe{record, {Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 1AM - 7PM"}}
+
+	// the record of the first run is a duplicate in the second
+	incremental, expected := incrementalAndFull(t, cfg, first, second)
+	if len(incremental.CountPerRecipe) != 1 || incremental.CountPerRecipe[0].Count != 2 {
+		t.Errorf("Expected 2 records counted, but got %v", incremental.CountPerRecipe)
+	}
+	if incremental.Dedupe == nil || incremental.Dedupe.Records != 3 || incremental.Dedupe.Dropped != 1 {
+		t.Errorf("Expected 1 of 3 records dropped, but got %+v", incremental.Dedupe)
+	}
+	if !reflect.DeepEqual(incremental, expected) {
+		t.Errorf("Expected %v, but got %v", expected, incremental)
+	}
+}
+
+func TestState_PendingDedupe(t *testing.T) {
+	cfg := config.Default().WithDedupe(true).WithDedupeCapacity(1000)
+	processed := []InputFile{{Path: "b.json", Size: 10, ModTime: time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC)}}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	st := NewState(cfg)
+	st.Files = processed
+	deduper, err := st.Deduper(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if _, err := st.Pending(cfg, processed); err == nil {
+		t.Errorf("Expected an error for a state missing the dedupe filter")
+	}
+	loaded := saveAndLoad(t, st, deduper, statePath)
+	if _, err := loaded.Pending(cfg, processed); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	if _, err := loaded.Deduper(cfg); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	// the filter only takes records of the same settings
+	for _, changed := range []config.Config{cfg.WithDedupeCapacity(2000), cfg.WithDedupeFalsePositive(0.01)} {
+		if _, err := loaded.Pending(changed, processed); err == nil {
+			t.Errorf("Expected an error for changed dedupe settings %d/%v", changed.DedupeCapacity, changed.DedupeFalsePositive)
+		}
+		if _, err := loaded.Deduper(changed); err == nil {
+			t.Errorf("Expected the filter to be rejected by dedupe settings %d/%v", changed.DedupeCapacity, changed.DedupeFalsePositive)
+		}
+	}
+
+	// the filter is a file next to the state, replaced by the next save
+	previous := loaded.DedupeFilter.Name
+	input := filepath.Join(t.TempDir(), "input.json")
+	writeRecipes(t, input, []parser.Recipe{{Postcode: "10224", Recipe: "Dill", Delivery: "Wednesday 1AM - 7PM"}})
+	p := parser.NewJsonParser(cfg.WithFile(input)).WithDeduper(deduper)
+	go p.Parse(context.Background())
+	for range p.Stream() {
+	}
+	next := saveAndLoad(t, loaded, deduper, statePath)
+	sidecars, err := filepath.Glob(statePath + ".dedupe-*")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	expected := []string{filepath.Join(filepath.Dir(statePath), next.DedupeFilter.Name)}
+	if next.DedupeFilter.Name == previous || !reflect.DeepEqual(sidecars, expected) {
+		t.Errorf("Expected only the new filter %v, but got %v", expected, sidecars)
+	}
+	if err := os.WriteFile(sidecars[0], []byte("corrupt"), 0o644); err != nil {
+		t.Fatalf("Error writing filter: %v", err)
+	}
+	if _, err := next.Deduper(cfg); err == nil {
+		t.Errorf("Expected an error for a filter not matching its checksum")
+	}
+}
+
+// saveAndLoad saves st with the records of d and loads it again
+func saveAndLoad(t *testing.T, st *State, d *parser.Deduper, path string) *State {
+	t.Helper()
+	if err := st.KeepDeduper(d); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if err := st.Save(path); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return loaded
+}
+
+func TestState_Pending(t *testing.T) {
+	cfg := config.Config{Words: []string{"Potato"}, Postcode: "10120", FromTime: "10AM", ToTime: "3PM"}
+	modTime := time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC)
+	processed := []InputFile{{Path: "b.json", Size: 10, ModTime: modTime}}
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		files    []InputFile
+		expected []InputFile
+		wantErr  bool
+	}{
+		{
+			name:     "new file",
+			cfg:      cfg,
+			files:    []InputFile{processed[0], {Path: "c.json", Size: 5, ModTime: modTime}},
+			expected: []InputFile{{Path: "c.json", Size: 5, ModTime: modTime}},
+		},
+		{
+			name:  "nothing new",
+			cfg:   cfg,
+			files: processed,
+		},
+		{
+			name:  "words in different case",
+			cfg:   cfg.WithWords([]string{"POTATO"}),
+			files: processed,
+		},
+		{
+			name:    "query changed",
+			cfg:     cfg.WithPostcode("10121"),
+			files:   processed,
+			wantErr: true,
+		},
+		{
+			name:    "field mapping changed",
+			cfg:     cfg.WithFieldMapping(config.FieldMapping{Postcode: "/zip"}),
+			files:   processed,
+			wantErr: true,
+		},
+		{
+			name:    "extended changed",
+			cfg:     cfg.WithExtended(true),
+			files:   processed,
+			wantErr: true,
+		},
+		{
+			name:    "rules changed",
+			cfg:     cfg.WithRules(config.Rules{PostcodePattern: `^\d{5}$`}),
+			files:   processed,
+			wantErr: true,
+		},
+		{
+			name:    "normalize changed",
+			cfg:     cfg.WithNormalize(true),
+			files:   processed,
+			wantErr: true,
+		},
+		{
+			name:    "aliases changed",
+			cfg:     cfg.WithAliases("../../cmd/stats/testdata/aliases.yaml"),
+			files:   processed,
+			wantErr: true,
+		},
+		{
+			name:    "dedupe changed",
+			cfg:     cfg.WithDedupe(true),
+			files:   processed,
+			wantErr: true,
+		},
+		{
+			name:    "processed file changed",
+			cfg:     cfg,
+			files:   []InputFile{{Path: "b.json", Size: 11, ModTime: modTime}},
+			wantErr: true,
+		},
+		{
+			name:    "processed file missing",
+			cfg:     cfg,
+			files:   []InputFile{{Path: "c.json", Size: 5, ModTime: modTime}},
+			wantErr: true,
+		},
+		{
+			name:    "new file sorts before processed file",
+			cfg:     cfg,
+			files:   []InputFile{{Path: "a.json", Size: 5, ModTime: modTime}, processed[0]},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		// avoid closure
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			st := NewState(cfg)
+			st.Files = processed
+			pending, err := st.Pending(tt.cfg, tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, but got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(pending, tt.expected) {
+				t.Errorf("Expected %v, but got %v", tt.expected, pending)
+			}
+		})
+	}
+}
+
+// generateWithState aggregates every file in cfg.File into a new state and saves it
+func generateWithState(t *testing.T, cfg config.Config, statePath string) *State {
+	t.Helper()
+	s := NewJsonStats(nil, cfg).WithState(NewState(cfg))
+	deduper, err := s.State().Deduper(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	for _, f := range statFiles(t, cfg.File) {
+		p := parser.NewJsonParser(cfg.WithFile(f.Path)).WithDeduper(deduper)
+		go p.Parse(context.Background())
+		if _, err := s.WithParser(p).Generate(context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		s.State().MarkProcessed(f)
+	}
+	if err := s.State().KeepDeduper(deduper); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if err := s.State().Save(statePath); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return s.State()
+}
+
+// incrementalAndFull aggregates first and second in two runs sharing a saved state,
+// the way incremental stats do, and both at once in a single run
+func incrementalAndFull(t *testing.T, cfg config.Config, first, second []parser.Recipe) (incremental, full ResponseData) {
+	t.Helper()
+	dir := t.TempDir()
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	writeRecipes(t, filepath.Join(dir, "2024-01-01.json"), first)
+	generateWithState(t, cfg.WithFile(dir), statePath)
+
+	writeRecipes(t, filepath.Join(dir, "2024-01-02.json"), second)
+	loaded, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	deduper, err := loaded.Deduper(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	p := parser.NewJsonParser(cfg.WithFile(filepath.Join(dir, "2024-01-02.json"))).WithDeduper(deduper)
+	if cfg.Normalize {
+		p.WithNormalizer(parser.NewNormalizer(cfg).WithNames(loaded.RecipeNames()))
+	}
+	go p.Parse(context.Background())
+	incremental, err = NewJsonStats(p, cfg).WithState(loaded).Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	allPath := filepath.Join(t.TempDir(), "all.json")
+	writeRecipes(t, allPath, append(slices.Clone(first), second...))
+	all := parser.NewJsonParser(cfg.WithFile(allPath))
+	go all.Parse(context.Background())
+	full, err = NewJsonStats(all, cfg).Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return incremental, full
+}
+
+func statFiles(t *testing.T, dir string) []InputFile {
+	t.Helper()
+	paths, err := parser.InputFiles(dir)
+	if err != nil {
+		t.Fatalf("Error listing files: %v", err)
+	}
+	files, err := StatInputFiles(paths)
+	if err != nil {
+		t.Fatalf("Error reading files: %v", err)
+	}
+	return files
+}
+
+func writeRecipes(t *testing.T, path string, recipes []parser.Recipe) {
+	t.Helper()
+	content, err := json.Marshal(recipes)
+	if err != nil {
+		t.Fatalf("Error encoding recipes: %v", err)
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("Error writing recipes: %v", err)
+	}
+}
@@ -1,102 +1,244 @@
 package stats
 
 import (
+	"context"
 	"slices"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rashad-j/jsonreader/pkg/config"
+	"github.com/rashad-j/jsonreader/pkg/metrics"
 	"github.com/rashad-j/jsonreader/pkg/parser"
 	"github.com/rs/zerolog/log"
 )
 
 type Stats interface {
-	Generate() (ResponseData, error)
+	// Generate reads the parser stream, it returns ctx.Err() once ctx is cancelled
+	Generate(ctx context.Context) (ResponseData, error)
 }
 
 type JsonStats struct {
-	parser parser.Parser
-	cfg    config.Config
+	parser   parser.Parser
+	cfg      config.Config
+	crosstab *Crosstab
+	state    *State
+	metrics  metrics.Metrics
 }
 
 func NewJsonStats(p parser.Parser, cfg config.Config) *JsonStats {
 	return &JsonStats{
-		parser: p,
-		cfg:    cfg,
+		parser:  p,
+		cfg:     cfg,
+		metrics: metrics.Nop{},
 	}
 }
 
-func (s *JsonStats) Generate() (ResponseData, error) {
-	// Initialize maps and slices for data analysis
-	recipeCounts := make(map[string]int, 2000)
-	postCodeCounts := make(map[string]int, 1000_000)
-	postCodeMaxDeliveries := ""
-	specificPostCodeDeliveries := 0
-	recipesContainingWords := make(map[string]int)
+// WithMetrics times every Generate call in m
+func (s *JsonStats) WithMetrics(m metrics.Metrics) *JsonStats {
+	s.metrics = m
+	return s
+}
 
-	// Convert words from slice to map for faster lookup
-	wordsMap := make(map[string]bool, len(s.cfg.Words))
-	for _, word := range s.cfg.Words {
-		wordsMap[strings.ToLower(word)] = true
+// WithParser replaces the parser, so one JsonStats can aggregate several input files
+func (s *JsonStats) WithParser(p parser.Parser) *JsonStats {
+	s.parser = p
+	return s
+}
+
+// WithState makes Generate continue from a previously aggregated state
+func (s *JsonStats) WithState(st *State) *JsonStats {
+	s.state = st
+	return s
+}
+
+// State returns the aggregated state, it is created by the first Generate call
+func (s *JsonStats) State() *State {
+	return s.state
+}
+
+// WithCrosstab makes Generate fill the crosstab during the same pass over the stream
+func (s *JsonStats) WithCrosstab(c *Crosstab) *JsonStats {
+	s.crosstab = c
+	return s
+}
+
+func (s *JsonStats) Generate(ctx context.Context) (ResponseData, error) {
+	defer s.observeQuery(time.Now())
+
+	if s.state == nil {
+		s.state = NewState(s.cfg)
 	}
 
-	// Read json content over stream
-	for entry := range s.parser.Stream() {
-		if entry.Error != nil {
-			log.Error().Err(entry.Error).Msg("failed to process entry")
-			continue
+	wordsMap := toWordsMap(s.cfg.Words)
+
+	// Read json content over stream, in batches to save a channel handoff per recipe
+	batches := s.parser.Batches()
+	var inputErr error
+	for {
+		batch, ok, err := next(ctx, batches)
+		if err != nil {
+			return ResponseData{}, err
+		}
+		if !ok {
+			break
 		}
+		for _, entry := range batch.Entries {
+			if entry.Error != nil {
+				inputErr = entryError(entry.Error, inputErr)
+				continue
+			}
 
-		// This is to count the number of unique recipes, and the total number of recipes
-		recipeCounts[entry.Recipe.Recipe]++
-		postCodeCounts[entry.Recipe.Postcode]++
+			s.addCounts(entry.Recipe)
+			if err := s.addQuery(entry.Recipe, wordsMap); err != nil {
+				batch.Release()
+				return ResponseData{}, err
+			}
+		}
+		batch.Release()
+	}
 
-		// Find postcode with most delivered recipes
-		if postCodeCounts[entry.Recipe.Postcode] > postCodeCounts[postCodeMaxDeliveries] {
-			postCodeMaxDeliveries = entry.Recipe.Postcode
+	if inputErr != nil {
+		return ResponseData{}, inputErr
+	}
+	s.state.addReport(s.parser)
+
+	return s.Response(), nil
+}
+
+// observeQuery records the duration of a Generate call started at start
+func (s *JsonStats) observeQuery(start time.Time) {
+	s.metrics.ObserveQuery(time.Since(start))
+}
+
+// next receives the next entry or batch of the stream, ok is false once the stream
+// is closed. It does not wait for the producer once ctx is cancelled, and a stream
+// closed by a cancelled producer is incomplete, so both return ctx.Err().
+func next[T any](ctx context.Context, stream <-chan T) (value T, ok bool, err error) {
+	select {
+	case <-ctx.Done():
+		return value, false, ctx.Err()
+	case value, ok = <-stream:
+		if !ok {
+			return value, false, ctx.Err()
 		}
+		return value, true, nil
+	}
+}
 
-		// Find recipes containing words
-		if s.containsWords(entry.Recipe.Recipe, wordsMap) {
-			recipesContainingWords[entry.Recipe.Recipe]++
+// entryError logs the error of a streamed entry. A malformed recipe is skipped, but
+// an unreadable input file is returned so the caller fails once the stream is drained.
+func entryError(err, inputErr error) error {
+	log.Error().Err(err).Msg("failed to process entry")
+	var parserErr *parser.InputError
+	if inputErr == nil && errors.As(err, &parserErr) {
+		return err
+	}
+	return inputErr
+}
+
+// addCounts counts the recipe and its postcode, which do not depend on the query
+func (s *JsonStats) addCounts(recipe parser.Recipe) {
+	st := s.state
+	// This is to count the number of unique recipes, and the total number of recipes
+	st.RecipeCounts[recipe.Recipe]++
+	st.PostcodeCounts[recipe.Postcode]++
+	if s.crosstab != nil {
+		s.crosstab.Add(recipe)
+	}
+	if recipe.Extra != nil {
+		st.addExtras(recipe)
+	}
+	if recipe.Alias != "" {
+		st.addAlias(recipe)
+	}
+
+	// Find postcode with most delivered recipes, the first in alphabetical order on a
+	// tie, so the result does not depend on the order of the records
+	count, busiest := st.PostcodeCounts[recipe.Postcode], st.PostcodeCounts[st.BusiestPostcode]
+	if count > busiest || (count == busiest && recipe.Postcode < st.BusiestPostcode) {
+		st.BusiestPostcode = recipe.Postcode
+	}
+}
+
+// addQuery counts the recipe for the words and the postcode/time window of the config
+func (s *JsonStats) addQuery(recipe parser.Recipe, wordsMap map[string]bool) error {
+	st := s.state
+	// Find recipes containing words
+	if s.containsWords(recipe.Recipe, wordsMap) {
+		st.MatchCounts[recipe.Recipe]++
+	}
+	// Number of deliveries for postcode and time range
+	if recipe.Postcode == s.cfg.Postcode {
+		inRange, err := s.isDeliveryTimeInRange(recipe.Delivery, s.cfg.FromTime, s.cfg.ToTime)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if delivery time is in range: %s", recipe.Delivery)
 		}
-		// Number of deliveries for postcode and time range
-		if entry.Recipe.Postcode == s.cfg.Postcode {
-			inRange, err := s.isDeliveryTimeInRange(entry.Recipe.Delivery, s.cfg.FromTime, s.cfg.ToTime)
-			if err != nil {
-				return ResponseData{}, errors.Wrapf(err, "failed to check if delivery time is in range: %s", entry.Recipe.Delivery)
-			}
-			if inRange {
-				specificPostCodeDeliveries++
-			}
+		if inRange {
+			st.PostcodeTimeCount++
 		}
 	}
+	return nil
+}
+
+// Response builds the stats from the aggregated state without reading the stream,
+// e.g. when a persisted state already covers every input file
+func (s *JsonStats) Response() ResponseData {
+	st := s.state
+	if st == nil {
+		st = NewState(s.cfg)
+	}
 
 	// sort recipe names alphabetically
-	sortedKeys := sortKeys(recipeCounts)
-	countPerRecipe := s.uniqueRecipeCount(sortedKeys, recipeCounts)
+	sortedKeys := sortKeys(st.RecipeCounts)
+	countPerRecipe := s.uniqueRecipeCount(sortedKeys, st.RecipeCounts)
+	if s.cfg.ShowAliases {
+		for i := range countPerRecipe {
+			countPerRecipe[i].Aliases = st.aliasCounts(countPerRecipe[i].Recipe)
+		}
+	}
 
 	// sort recipes containing words alphabetically
-	matchByName := sortKeys(recipesContainingWords)
+	matchByName := sortKeys(st.MatchCounts)
 
 	responseData := ResponseData{
-		UniqueRecipeCount: len(recipeCounts),
+		UniqueRecipeCount: len(st.RecipeCounts),
 		CountPerRecipe:    countPerRecipe,
 		BusiestPostcode: BusiestPostcode{
-			Postcode:      postCodeMaxDeliveries,
-			DeliveryCount: postCodeCounts[postCodeMaxDeliveries],
+			Postcode:      st.BusiestPostcode,
+			DeliveryCount: st.PostcodeCounts[st.BusiestPostcode],
 		},
 		CountPerPostcodeAndTime: CountPerPostcodeAndTime{
-			Postcode:      s.cfg.Postcode,
-			From:          s.cfg.FromTime,
-			To:            s.cfg.ToTime,
-			DeliveryCount: specificPostCodeDeliveries,
+			Postcode:      st.Query.Postcode,
+			From:          st.Query.From,
+			To:            st.Query.To,
+			DeliveryCount: st.PostcodeTimeCount,
 		},
 		MatchByName: matchByName,
 	}
+	if s.crosstab != nil {
+		responseData.Crosstab = s.crosstab.Result()
+	}
+	if s.cfg.Extended {
+		responseData.Extended = st.extendedStats()
+	}
+	if s.cfg.Normalize {
+		responseData.Normalized = st.normalized()
+	}
+	if s.cfg.Dedupe {
+		responseData.Dedupe = st.deduped()
+	}
+
+	return responseData
+}
 
-	return responseData, nil
+// toWordsMap converts words from slice to map for faster lookup
+func toWordsMap(words []string) map[string]bool {
+	wordsMap := make(map[string]bool, len(words))
+	for _, word := range words {
+		wordsMap[strings.ToLower(word)] = true
+	}
+	return wordsMap
 }
 
 // containsWords checks if the recipe contains any of the words
@@ -126,24 +268,10 @@ func (s *JsonStats) isDeliveryTimeInRange(delivery string, startHour, endHour st
 		return false, errors.Wrapf(err, "failed to parse end hour: %s", endHour)
 	}
 
-	// split delivery string into day and time
-	deliverySplit := strings.SplitN(delivery, " ", 2)
-	if len(deliverySplit) != 2 {
-		return false, errors.New("failed to split delivery string")
-	}
-	// split time into start and end time
-	deliveryTimeSplit := strings.SplitN(deliverySplit[1], " - ", 2)
-	if len(deliveryTimeSplit) != 2 {
-		return false, errors.New("failed to split delivery time")
-	}
-	// parse split start and end time into int
-	deliveryStartHourInt, err := parseHour(deliveryTimeSplit[0])
+	// parse the delivery the way the parser accepted it, e.g. also "Monday 9AM-5PM"
+	_, deliveryStartHourInt, deliveryEndHourInt, err := parser.ParseDelivery(delivery)
 	if err != nil {
-		return false, errors.Wrapf(err, "failed to parse delivery start hour: %s", deliveryTimeSplit[0])
-	}
-	deliveryEndHourInt, err := parseHour(deliveryTimeSplit[1])
-	if err != nil {
-		return false, errors.Wrapf(err, "failed to parse delivery end hour: %s", deliveryTimeSplit[1])
+		return false, errors.Wrap(err, "failed to parse delivery hours")
 	}
 
 	// check if delivery time is within range
@@ -170,32 +298,7 @@ func (s *JsonStats) uniqueRecipeCount(sortedKeys []string, recipeCounts map[stri
 
 // parseHour a helper function to parse the hour from the delivery time
 func parseHour(hourString string) (int, error) {
-	// remove AM/PM
-	hourTrimmed := strings.TrimSuffix(hourString, "AM")
-	hourTrimmed = strings.TrimSuffix(hourTrimmed, "PM")
-
-	// convert to int
-	hourInt, err := strconv.Atoi(hourTrimmed)
-	if err != nil {
-		return 0, err
-	}
-
-	// check the range between 1 and 12
-	if hourInt < 1 || hourInt > 12 {
-		return 0, errors.New("hour is not in range 1-12")
-	}
-
-	// 12AM is 0
-	if strings.Contains(hourString, "AM") && hourInt == 12 {
-		hourInt = 0
-	}
-
-	// if PM, add 12 hours
-	if strings.Contains(hourString, "PM") {
-		hourInt += 12
-	}
-
-	return hourInt, nil
+	return config.ParseHour(hourString)
 }
 
 // uniqueRecipeCount a helper function to sort the keys alphabetically
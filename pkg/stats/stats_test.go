@@ -3,8 +3,13 @@ package stats
 // Unit tests for the stats package
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"reflect"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/rashad-j/jsonreader/pkg/config"
 	"github.com/rashad-j/jsonreader/pkg/parser"
@@ -112,6 +117,20 @@ func TestJsonStats_IsDeliveryTimeInRange(t *testing.T) {
 			endHour:   "3AM",
 			want:      false,
 		},
+		{
+			name:      "delivery ends at noon",
+			delivery:  "Monday 9AM - 12PM",
+			startHour: "10AM",
+			endHour:   "3PM",
+			want:      false,
+		},
+		{
+			name:      "delivery without spaces around the dash",
+			delivery:  "Monday 9AM-5PM",
+			startHour: "10AM",
+			endHour:   "3PM",
+			want:      true,
+		},
 		{
 			name:      "start delivery time is 12AM",
 			delivery:  "Monday 12AM - 11PM",
@@ -211,7 +230,7 @@ func TestJsonStats_ParseHour(t *testing.T) {
 		{
 			name:     "Normal Case 12PM",
 			hour:     "12PM",
-			expected: 24,
+			expected: 12,
 		},
 		{
 			name:     "Normal Case 12AM",
@@ -249,3 +268,84 @@ func TestJsonStats_ParseHour(t *testing.T) {
 		})
 	}
 }
+
+func TestJsonStats_GenerateNoGoroutineLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		cancel bool
+	}{
+		{
+			// Generate returns early on the time range error, the producer is still sending
+			name: "time range error",
+			cfg:  config.Default().WithFile("testdata/test.json").WithPostcode("10120").WithFromTime("10").WithToTime("3PM"),
+		},
+		{
+			name:   "cancelled",
+			cfg:    config.Default().WithFile("testdata/test.json"),
+			cancel: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // avoid closure
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			p := parser.NewJsonParser(tt.cfg)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				p.Parse(ctx)
+			}()
+			if tt.cancel {
+				cancel()
+			}
+
+			if _, err := NewJsonStats(p, tt.cfg).Generate(ctx); err == nil {
+				t.Fatal("Expected an error, but got nil")
+			}
+			cancel()
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("Expected the parser goroutine to exit, but it is still running")
+			}
+		})
+	}
+
+	// nothing else is left behind either
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected %d goroutines, but got %d", before, after)
+	}
+}
+
+func TestJsonStats_EnginesIdentical(t *testing.T) {
+	cfg := config.Default().WithFile("testdata/test.json")
+
+	var outputs [][]byte
+	for _, engine := range []string{"json", "fast"} {
+		engineCfg := cfg.WithEngine(engine)
+		p := parser.NewJsonParser(engineCfg)
+		go p.Parse(context.Background())
+		data, err := NewJsonStats(p, engineCfg).Generate(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		output, err := json.Marshal(data)
+		if err != nil {
+			t.Fatalf("Error encoding stats: %v", err)
+		}
+		outputs = append(outputs, output)
+	}
+
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Errorf("Expected %s, but got %s", outputs[0], outputs[1])
+	}
+}
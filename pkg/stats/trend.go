@@ -0,0 +1,41 @@
+package stats
+
+// TrendPoint is the stats of one dated input file
+type TrendPoint struct {
+	Date string
+	Data ResponseData
+}
+
+// Trend turns stats per date into time series aligned with Dates, so recipe
+// popularity can be charted over time. Recipes missing on a date count 0.
+func Trend(points []TrendPoint) TrendData {
+	trend := TrendData{
+		Dates:             make([]string, 0, len(points)),
+		UniqueRecipeCount: make([]int, 0, len(points)),
+		CountPerRecipe:    make(map[string][]int),
+		CountPerPostcodeAndTime: TrendPostcodeAndTime{
+			DeliveryCount: make([]int, 0, len(points)),
+		},
+	}
+
+	for i, point := range points {
+		trend.Dates = append(trend.Dates, point.Date)
+		trend.UniqueRecipeCount = append(trend.UniqueRecipeCount, point.Data.UniqueRecipeCount)
+		for _, rc := range point.Data.CountPerRecipe {
+			series, ok := trend.CountPerRecipe[rc.Recipe]
+			if !ok {
+				series = make([]int, len(points))
+				trend.CountPerRecipe[rc.Recipe] = series
+			}
+			series[i] = rc.Count
+		}
+
+		window := point.Data.CountPerPostcodeAndTime
+		trend.CountPerPostcodeAndTime.Postcode = window.Postcode
+		trend.CountPerPostcodeAndTime.From = window.From
+		trend.CountPerPostcodeAndTime.To = window.To
+		trend.CountPerPostcodeAndTime.DeliveryCount = append(trend.CountPerPostcodeAndTime.DeliveryCount, window.DeliveryCount)
+	}
+
+	return trend
+}
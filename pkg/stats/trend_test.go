@@ -0,0 +1,42 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrend(t *testing.T) {
+	points := []TrendPoint{
+		{
+			Date: "2024-01-01",
+			Data: ResponseData{
+				UniqueRecipeCount:       2,
+				CountPerRecipe:          []RecipeCount{{Recipe: "RecipeA", Count: 2}, {Recipe: "RecipeB", Count: 1}},
+				CountPerPostcodeAndTime: CountPerPostcodeAndTime{Postcode: "10120", From: "10AM", To: "3PM", DeliveryCount: 1},
+			},
+		},
+		{
+			Date: "2024-01-02",
+			Data: ResponseData{
+				UniqueRecipeCount:       2,
+				CountPerRecipe:          []RecipeCount{{Recipe: "RecipeA", Count: 4}, {Recipe: "RecipeC", Count: 3}},
+				CountPerPostcodeAndTime: CountPerPostcodeAndTime{Postcode: "10120", From: "10AM", To: "3PM", DeliveryCount: 0},
+			},
+		},
+	}
+
+	expected := TrendData{
+		Dates:             []string{"2024-01-01", "2024-01-02"},
+		UniqueRecipeCount: []int{2, 2},
+		CountPerRecipe: map[string][]int{
+			"RecipeA": {2, 4},
+			"RecipeB": {1, 0},
+			"RecipeC": {0, 3},
+		},
+		CountPerPostcodeAndTime: TrendPostcodeAndTime{Postcode: "10120", From: "10AM", To: "3PM", DeliveryCount: []int{1, 0}},
+	}
+
+	if got := Trend(points); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, got)
+	}
+}
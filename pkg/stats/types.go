@@ -1,8 +1,18 @@
 package stats
 
+import "github.com/rashad-j/jsonreader/pkg/parser"
+
 type RecipeCount struct {
 	Recipe string `json:"recipe"`
 	Count  int    `json:"count"`
+	// Aliases are the names counted under the recipe, listed with config.ShowAliases
+	Aliases []AliasCount `json:"aliases,omitempty"`
+}
+
+// AliasCount is the number of records of a recipe read under one of its aliases
+type AliasCount struct {
+	Alias string `json:"alias"`
+	Count int    `json:"count"`
 }
 
 type BusiestPostcode struct {
@@ -23,4 +33,109 @@ type ResponseData struct {
 	BusiestPostcode         BusiestPostcode         `json:"busiest_postcode"`
 	CountPerPostcodeAndTime CountPerPostcodeAndTime `json:"count_per_postcode_and_time"`
 	MatchByName             []string                `json:"match_by_name"`
+	Crosstab                []PostcodeRecipes       `json:"crosstab,omitempty"`
+	Extended                *ExtendedStats          `json:"extended,omitempty"`
+	Normalized              *parser.NormalizeReport `json:"normalized,omitempty"`
+	Dedupe                  *parser.DedupeReport    `json:"dedupe,omitempty"`
+}
+
+// ExtendedStats sums the optional box_size and price fields of the records, per
+// recipe for the recipes having them
+type ExtendedStats struct {
+	TotalBoxUnits int              `json:"total_box_units"`
+	TotalRevenue  float64          `json:"total_revenue"`
+	PerRecipe     []RecipeExtended `json:"per_recipe"`
+}
+
+type RecipeExtended struct {
+	Recipe   string  `json:"recipe"`
+	BoxUnits int     `json:"box_units"`
+	Revenue  float64 `json:"revenue"`
+}
+
+type PostcodeRecipes struct {
+	Postcode      string        `json:"postcode"`
+	DeliveryCount int           `json:"delivery_count"`
+	Recipes       []RecipeCount `json:"recipes"`
+}
+
+// Estimate is an approximate value, within ErrorBound of the exact value with
+// probability Confidence
+type Estimate struct {
+	Estimate   int     `json:"estimate"`
+	ErrorBound int     `json:"error_bound"`
+	Confidence float64 `json:"confidence"`
+}
+
+type ApproxRecipeCount struct {
+	Recipe string   `json:"recipe"`
+	Count  Estimate `json:"count"`
+}
+
+type ApproxBusiestPostcode struct {
+	Postcode      string   `json:"postcode"`
+	DeliveryCount Estimate `json:"delivery_count"`
+}
+
+type ApproxResponseData struct {
+	TotalDeliveries         int                     `json:"total_deliveries"`
+	UniqueRecipeCount       Estimate                `json:"unique_recipe_count"`
+	UniquePostcodeCount     Estimate                `json:"unique_postcode_count"`
+	TopRecipes              []ApproxRecipeCount     `json:"top_recipes"`
+	BusiestRecipe           ApproxRecipeCount       `json:"busiest_recipe"`
+	BusiestPostcode         ApproxBusiestPostcode   `json:"busiest_postcode"`
+	CountPerPostcodeAndTime CountPerPostcodeAndTime `json:"count_per_postcode_and_time"`
+	MatchByName             []string                `json:"match_by_name"`
+	Normalized              *parser.NormalizeReport `json:"normalized,omitempty"`
+	Dedupe                  *parser.DedupeReport    `json:"dedupe,omitempty"`
+}
+
+type CountDelta struct {
+	Old   int `json:"old"`
+	New   int `json:"new"`
+	Delta int `json:"delta"`
+}
+
+type RecipeDelta struct {
+	Recipe string `json:"recipe"`
+	Old    int    `json:"old"`
+	New    int    `json:"new"`
+	Delta  int    `json:"delta"`
+}
+
+type BusiestPostcodeDiff struct {
+	Changed bool            `json:"changed"`
+	Old     BusiestPostcode `json:"old"`
+	New     BusiestPostcode `json:"new"`
+}
+
+type PostcodeAndTimeDiff struct {
+	Old   CountPerPostcodeAndTime `json:"old"`
+	New   CountPerPostcodeAndTime `json:"new"`
+	Delta int                     `json:"delta"`
+}
+
+type DiffData struct {
+	UniqueRecipeCount       CountDelta          `json:"unique_recipe_count"`
+	AddedRecipes            []RecipeCount       `json:"added_recipes"`
+	RemovedRecipes          []RecipeCount       `json:"removed_recipes"`
+	CountDeltas             []RecipeDelta       `json:"count_deltas"`
+	BusiestPostcode         BusiestPostcodeDiff `json:"busiest_postcode"`
+	CountPerPostcodeAndTime PostcodeAndTimeDiff `json:"count_per_postcode_and_time"`
+	AddedMatches            []string            `json:"added_matches"`
+	RemovedMatches          []string            `json:"removed_matches"`
+}
+
+type TrendPostcodeAndTime struct {
+	Postcode      string `json:"postcode"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	DeliveryCount []int  `json:"delivery_count"`
+}
+
+type TrendData struct {
+	Dates                   []string             `json:"dates"`
+	UniqueRecipeCount       []int                `json:"unique_recipe_count"`
+	CountPerRecipe          map[string][]int     `json:"count_per_recipe"`
+	CountPerPostcodeAndTime TrendPostcodeAndTime `json:"count_per_postcode_and_time"`
 }